@@ -0,0 +1,43 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Peripli/istio-broker-proxy/pkg/router"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestKubernetesBindingLock(t *testing.T) {
+	skipWithoutKubeconfigSet(t)
+
+	g := NewGomegaWithT(t)
+	clientcmd.ClusterDefaults.Server = ""
+	cfg, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	g.Expect(err).NotTo(HaveOccurred())
+	clientset, err := kubernetes.NewForConfig(cfg)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	replica1 := router.NewKubeBindingLock(clientset, "default", "replica-1")
+	replica2 := router.NewKubeBindingLock(clientset, "default", "replica-2")
+	defer replica1.Unlock(context.Background(), "123456789-lock")
+
+	locked, err := replica1.TryLock(context.Background(), "123456789-lock")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+
+	locked, err = replica2.TryLock(context.Background(), "123456789-lock")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeFalse())
+
+	g.Expect(replica2.Unlock(context.Background(), "123456789-lock")).To(Succeed())
+	g.Expect(replica1.Unlock(context.Background(), "123456789-lock")).To(Succeed())
+
+	locked, err = replica2.TryLock(context.Background(), "123456789-lock")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+	g.Expect(replica2.Unlock(context.Background(), "123456789-lock")).To(Succeed())
+}
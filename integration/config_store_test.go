@@ -24,7 +24,7 @@ func TestKubernetesCreateService(t *testing.T) {
 	kubectl.Delete("Service", service.Name)
 
 	configStore := router.NewExternKubeConfigStore("default")
-	service, err := configStore.CreateService("123456789-1", service)
+	service, err := configStore.CreateService("", "123456789-1", service)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(service.Spec.ClusterIP).ToNot(BeEmpty())
 
@@ -62,7 +62,7 @@ func TestKubernetesCreateIstioConfig(t *testing.T) {
 
 	clientcmd.ClusterDefaults.Server = ""
 	configStore := router.NewExternKubeConfigStore("default")
-	err := configStore.CreateIstioConfig("123456789", []model.Config{cfg})
+	err := configStore.ApplyIstioConfig("", "123456789", []model.Config{cfg})
 	g.Expect(err).ToNot(HaveOccurred())
 
 	g.Expect(checkIfServiceExists(kubectl, "foo=bar")).To(BeTrue())
@@ -88,7 +88,7 @@ func TestKubernetesCreateIstioObjects(t *testing.T) {
 
 	for _, configuration := range configurations {
 
-		err := configStore.CreateIstioConfig("", []model.Config{configuration})
+		err := configStore.ApplyIstioConfig("", "", []model.Config{configuration})
 		g.Expect(err).NotTo(HaveOccurred(), "error creating %#v\n", configuration)
 	}
 
@@ -4,9 +4,18 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
 	"github.com/Peripli/istio-broker-proxy/pkg/router"
+	"github.com/Peripli/istio-broker-proxy/pkg/tracing"
+	"github.com/gin-gonic/gin"
 	"istio.io/istio/pkg/log"
+	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var producerInterceptor router.ProducerInterceptor
@@ -15,6 +24,19 @@ var routerConfig router.Config
 var serviceNamePrefix string
 var networkProfile string
 var configStore string
+var brokerRegistry string
+var minBrokerAPIVersion string
+var basicAuthFile string
+var serverTLS router.ServerTLSConfig
+var allowedClientIDs string
+var upstreamCredentialsFile string
+var upstreamCredentialsEnv string
+var traceExporter string
+var auditLog string
+var upstreamOperationTimeouts string
+var bearerTokenFile string
+var shutdownTimeout time.Duration
+var shutdownGracePeriod time.Duration
 var logLevel int
 var version string
 
@@ -33,37 +55,143 @@ func newConfigStore(configStoreURL string) (router.ConfigStore, error) {
 	}
 }
 
+func newBindingLock(configStoreURL string) (router.BindingLock, error) {
+	uri, err := url.Parse(configStoreURL)
+	if err != nil {
+		return nil, err
+	}
+	// replicas share the kubernetes config store, so they need a shared lock as well
+	if uri.Scheme == "k8s" {
+		return router.NewInClusterBindingLock(), nil
+	}
+	return router.NewInProcessBindingLock(), nil
+}
+
 func newConfigStoreOrFail(configStoreURL string) router.ConfigStore {
 	store, err := newConfigStore(configStoreURL)
 	if err != nil {
 		panic(err)
 	}
-	return store
+	return router.NewInstrumentedConfigStore(store)
 }
 
 func main() {
 	SetupConfiguration()
 	flag.Parse()
 	configureLogging()
+	err := configureTracing(traceExporter)
+	if err != nil {
+		panic(err)
+	}
+	bindingLock, err := newBindingLock(configStore)
+	if err != nil {
+		panic(err)
+	}
+	routerConfig.BindingLock = bindingLock
+	if minBrokerAPIVersion != "" {
+		minVersion, err := model.ParseBrokerAPIVersion(minBrokerAPIVersion)
+		if err != nil {
+			panic(err)
+		}
+		routerConfig.MinBrokerAPIVersion = &minVersion
+	}
+	routerConfig.RequireClientCertificate = serverTLS.Enabled() && serverTLS.ClientCAFile != ""
+	if basicAuthFile != "" {
+		routerConfig.Authenticators = append(routerConfig.Authenticators, router.NewBasicAuthenticator(basicAuthFile))
+	}
+	if bearerTokenFile != "" {
+		routerConfig.Authenticators = append(routerConfig.Authenticators, router.NewBearerTokenAuthenticator(bearerTokenFile))
+	}
+	if upstreamCredentialsFile != "" {
+		routerConfig.UpstreamCredentials = router.NewFileUpstreamCredentials(upstreamCredentialsFile)
+	} else if upstreamCredentialsEnv != "" {
+		routerConfig.UpstreamCredentials = router.NewEnvUpstreamCredentials(upstreamCredentialsEnv)
+	}
+	routerConfig.Upstream.OperationTimeouts, err = router.ParseOperationTimeouts(upstreamOperationTimeouts)
+	if err != nil {
+		panic(err)
+	}
+	if brokerRegistry != "" {
+		routerConfig.Brokers, err = router.LoadBrokerRegistry(brokerRegistry)
+		if err != nil {
+			panic(err)
+		}
+	}
+	routerConfig.Lifecycle = router.NewLifecycle()
 	engine := router.SetupRouterWithVersion(configureInterceptor(newConfigStoreOrFail), routerConfig, version)
-	engine.Run(fmt.Sprintf(":%d", routerConfig.Port))
+	err = run(engine)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func run(engine *gin.Engine) error {
+	server := &http.Server{Addr: fmt.Sprintf(":%d", routerConfig.Port), Handler: engine}
+	serve := server.ListenAndServe
+	if serverTLS.Enabled() {
+		serverTLS.AllowedClientIDs = splitList(allowedClientIDs)
+		tlsConfig, err := serverTLS.NewTLSConfig()
+		if err != nil {
+			return err
+		}
+		log.Infof("Serving HTTPS, client certificates verified: %t\n", tlsConfig.ClientCAs != nil)
+		server.TLSConfig = tlsConfig
+		serve = func() error {
+			return server.ListenAndServeTLS(serverTLS.CertFile, serverTLS.KeyFile)
+		}
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve()
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-serveErr:
+		return err
+	case received := <-signals:
+		log.Infof("Received %s, shutting down after %s within %s\n", received, shutdownGracePeriod, shutdownTimeout)
+		return routerConfig.Lifecycle.Shutdown(server, shutdownGracePeriod, shutdownTimeout)
+	}
+}
+
+// splitList splits a comma separated flag value and drops the blanks around and between the entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
 }
 
 func configureInterceptor(configStoreFactory func(configStoreUrl string) router.ConfigStore) router.ServiceBrokerInterceptor {
 	if networkProfile == "" {
 		panic("networkProfile not configured")
 	}
+	var audit router.AuditLog
+	if auditLog != "" {
+		var err error
+		audit, err = router.OpenAuditLog(auditLog)
+		if err != nil {
+			panic(fmt.Sprintf("unable to open audit log: %v", err))
+		}
+	}
 	log.Infof("Running on port %d\n", routerConfig.Port)
 	var interceptor router.ServiceBrokerInterceptor
 	if consumerInterceptor.ConsumerID != "" {
 		consumerInterceptor.ServiceNamePrefix = serviceNamePrefix
 		consumerInterceptor.NetworkProfile = networkProfile
 		consumerInterceptor.ConfigStore = configStoreFactory(configStore)
+		consumerInterceptor.AuditLog = audit
 		interceptor = consumerInterceptor
 	} else if producerInterceptor.ProviderID != "" {
 		producerInterceptor.ServiceNamePrefix = serviceNamePrefix
 		producerInterceptor.NetworkProfile = networkProfile
 		producerInterceptor.ConfigStore = configStoreFactory(configStore)
+		producerInterceptor.AuditLog = audit
 		err := producerInterceptor.WriteIstioConfigFiles(routerConfig.Port)
 		if err != nil {
 			panic(fmt.Sprintf("unable to write istio-broker provider side configuration file: %v", err))
@@ -75,6 +203,18 @@ func configureInterceptor(configStoreFactory func(configStoreUrl string) router.
 	return interceptor
 }
 
+func configureTracing(exporter string) error {
+	switch exporter {
+	case "":
+		tracing.SetExporter(nil)
+	case "log":
+		tracing.SetExporter(tracing.NewLogExporter())
+	default:
+		return errors.New("Invalid trace exporter:" + exporter)
+	}
+	return nil
+}
+
 func configureLogging() {
 	options := log.DefaultOptions()
 	options.SetOutputLevel(log.DefaultScopeName, log.Level(logLevel))
@@ -101,6 +241,32 @@ func SetupConfiguration() {
 
 	flag.StringVar(&routerConfig.ForwardURL, "forwardUrl", "", "url for forwarding incoming requests")
 	flag.BoolVar(&routerConfig.SkipVerifyTLS, "skipVerifyTLS", false, "Do not verify the certificate of the forwardUrl")
+	flag.StringVar(&routerConfig.CACertFile, "caCertFile", "", "CA bundle to verify the certificate of the forwardUrl instead of the system CAs")
+	flag.StringVar(&routerConfig.ClientCertFile, "clientCertFile", "", "Client certificate to authenticate at the forwardUrl")
+	flag.StringVar(&routerConfig.ClientKeyFile, "clientKeyFile", "", "Key file of the clientCertFile")
+	flag.StringVar(&upstreamCredentialsFile, "upstreamCredentialsFile", "", "File with <user>:<password> replacing the authorization of incoming requests at the forwardUrl and at the brokers without own credentials")
+	flag.StringVar(&upstreamCredentialsEnv, "upstreamCredentialsEnv", "", "Environment variable with <user>:<password> replacing the authorization of incoming requests at the forwardUrl and at the brokers without own credentials")
+	flag.StringVar(&routerConfig.ServerName, "serverName", "", "Server name expected in the certificate of the forwardUrl instead of its host")
+	flag.DurationVar(&routerConfig.Upstream.Timeout, "upstreamTimeout", time.Minute, "Timeout of each call to the forwardUrl, 0 to wait until the incoming request is cancelled")
+	flag.StringVar(&upstreamOperationTimeouts, "upstreamOperationTimeouts", "", "Comma separated timeouts per operation overriding the upstreamTimeout, e.g. bind=2m,catalog=10s")
+	flag.IntVar(&routerConfig.Upstream.Retries, "upstreamRetries", 2, "Retries of failed catalog, unbind and fetch binding calls to the forwardUrl")
+	flag.DurationVar(&routerConfig.Upstream.InitialBackoff, "upstreamBackoff", 500*time.Millisecond, "Delay before the first retry, doubled for each further retry")
+	flag.DurationVar(&routerConfig.Upstream.MaxBackoff, "upstreamMaxBackoff", 5*time.Second, "Maximum delay between retries")
+	flag.IntVar(&routerConfig.CircuitBreaker.FailureThreshold, "circuitBreakerFailures", 5, "Consecutive failed calls to the forwardUrl which let further calls fail fast, 0 disables the circuit breaker")
+	flag.DurationVar(&routerConfig.CircuitBreaker.OpenTimeout, "circuitBreakerOpenTimeout", 30*time.Second, "Time calls fail fast before a trial call to the forwardUrl is made")
+	flag.IntVar(&routerConfig.CircuitBreaker.SuccessThreshold, "circuitBreakerSuccesses", 1, "Successful trial calls which close the circuit breaker again")
 	flag.IntVar(&routerConfig.Port, "port", router.DefaultPort, "Server listen port")
+	flag.DurationVar(&shutdownGracePeriod, "shutdownGracePeriod", 10*time.Second, "Time requests are still served on SIGTERM while the readiness probe fails, at least its period")
+	flag.DurationVar(&shutdownTimeout, "shutdownTimeout", 15*time.Second, "Time binds in flight get to finish on SIGTERM before they are aborted and rolled back")
 	flag.StringVar(&serviceNamePrefix, "serviceNamePrefix", "", "Service name prefix")
+	flag.StringVar(&minBrokerAPIVersion, "minBrokerAPIVersion", "", "Minimum X-Broker-API-Version of incoming requests, empty to accept all versions")
+	flag.StringVar(&basicAuthFile, "basicAuthFile", "", "File with <user>:<password> lines accepted as basic auth credentials, reloaded on change")
+	flag.StringVar(&bearerTokenFile, "bearerTokenFile", "", "File with one accepted bearer token per line, reloaded on change")
+	flag.StringVar(&serverTLS.CertFile, "tlsCertFile", "", "Certificate file to serve HTTPS instead of HTTP")
+	flag.StringVar(&serverTLS.KeyFile, "tlsKeyFile", "", "Key file of the tlsCertFile")
+	flag.StringVar(&serverTLS.ClientCAFile, "clientCAFile", "", "CA file to require and verify client certificates")
+	flag.StringVar(&allowedClientIDs, "allowedClientIds", "", "Comma separated subject alternative names of accepted client certificates, e.g. the consumerIds of the consumer proxies")
+	flag.StringVar(&auditLog, "auditLog", "", "Sink of the JSON audit log of provisions, bindings and unbindings, 'stdout' or a file, empty disables the audit")
+	flag.StringVar(&traceExporter, "traceExporter", "", "Exporter of the spans of each request, 'log' writes them to the log, empty disables the export")
+	flag.StringVar(&brokerRegistry, "brokerRegistry", "", "JSON file with forwardUrl, skipVerifyTLS, serviceNamePrefix and networkProfile per broker id of /v1/osb/:broker_id requests")
 }
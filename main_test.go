@@ -57,6 +57,13 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+func TestSplitList(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(splitList(" consumer-a, ,consumer-b ,")).To(Equal([]string{"consumer-a", "consumer-b"}))
+	g.Expect(splitList("")).To(BeEmpty())
+}
+
 func TestNewConfigStoreInvalidSchema(t *testing.T) {
 	g := NewGomegaWithT(t)
 	_, err := newConfigStore("xxx://")
@@ -75,6 +82,19 @@ func TestNewConfigStoreInvalidURL(t *testing.T) {
 	g.Expect(err).To(HaveOccurred())
 }
 
+func TestNewBindingLockFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock, err := newBindingLock("file:///tmp")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lock).To(Equal(router.NewInProcessBindingLock()))
+}
+
+func TestNewBindingLockInvalidURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+	_, err := newBindingLock("\x7f")
+	g.Expect(err).To(HaveOccurred())
+}
+
 func TestLogLevelDebug(t *testing.T) {
 	g := NewGomegaWithT(t)
 	fmt.Printf("%s", os.Getenv("LOG"))
@@ -132,3 +152,11 @@ func TestNoDebugMessageInInfo(t *testing.T) {
 	g.Expect(out.String()).To(ContainSubstring("Log level is set to 4"))
 	g.Expect(out.String()).NotTo(ContainSubstring("Debug logging enabled"))
 }
+
+func TestConfigureTracing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(configureTracing("log")).To(Succeed())
+	g.Expect(configureTracing("")).To(Succeed())
+	g.Expect(configureTracing("zipkin")).NotTo(Succeed())
+}
@@ -1,14 +1,22 @@
 package api
 
+import (
+	"net/http"
+	"net/url"
+)
+
 //RESTResponse encapsulates operations for a RESTResponse
 type RESTResponse interface {
 	Into(response interface{}) error
 	Error() error
+	StatusCode() int
+	Header() http.Header
 }
 
 //RESTRequest encapsulates operations for a RESTRequest
 type RESTRequest interface {
 	AppendPath(path string) RESTRequest
+	SetQuery(query url.Values) RESTRequest
 	Do() RESTResponse
 }
 
@@ -17,5 +25,6 @@ type RESTClient interface {
 	Get() RESTRequest
 	Post(body interface{}) RESTRequest
 	Put(body interface{}) RESTRequest
+	Patch(body interface{}) RESTRequest
 	Delete() RESTRequest
 }
@@ -49,7 +49,7 @@ func createOutput(clientConfig bool, serviceName string, hostVirtualService stri
 			err = configStore.DeleteBinding(id)
 
 		} else {
-			_, err = router.CreateIstioObjectsInK8S(configStore, id, serviceName, m.Endpoint{Host: hostVirtualService, Port: 9000}, systemDomain)
+			_, err = router.CreateIstioObjectsInK8S(configStore, "", id, serviceName, m.Endpoint{Host: hostVirtualService, Port: 9000}, systemDomain)
 		}
 		if err != nil {
 			fmt.Printf("error occured: %s", err.Error())
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//BrokerAPIVersion is the version of the open service broker API as sent in the X-Broker-API-Version header
+type BrokerAPIVersion struct {
+	Major int
+	Minor int
+}
+
+//ParseBrokerAPIVersion parses a version of the form <major>.<minor>
+func ParseBrokerAPIVersion(version string) (BrokerAPIVersion, error) {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) != 2 {
+		return BrokerAPIVersion{}, fmt.Errorf("invalid broker API version %q", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return BrokerAPIVersion{}, fmt.Errorf("invalid broker API version %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return BrokerAPIVersion{}, fmt.Errorf("invalid broker API version %q", version)
+	}
+	return BrokerAPIVersion{major, minor}, nil
+}
+
+//Supports returns true if a request of this version can be served by a broker requiring the given minimum version
+func (v BrokerAPIVersion) Supports(minimum BrokerAPIVersion) bool {
+	return v.Major == minimum.Major && v.Minor >= minimum.Minor
+}
+
+func (v BrokerAPIVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestParseBrokerAPIVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	version, err := ParseBrokerAPIVersion("2.14")
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(version).To(Equal(BrokerAPIVersion{2, 14}))
+	g.Expect(version.String()).To(Equal("2.14"))
+}
+
+func TestParseInvalidBrokerAPIVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for _, version := range []string{"", "2", "2.x", "x.14", "2.14.1"} {
+		_, err := ParseBrokerAPIVersion(version)
+		g.Expect(err).To(HaveOccurred(), version)
+	}
+}
+
+func TestBrokerAPIVersionSupports(t *testing.T) {
+	g := NewGomegaWithT(t)
+	minimum := BrokerAPIVersion{2, 14}
+
+	g.Expect(BrokerAPIVersion{2, 14}.Supports(minimum)).To(BeTrue())
+	g.Expect(BrokerAPIVersion{2, 15}.Supports(minimum)).To(BeTrue())
+	g.Expect(BrokerAPIVersion{2, 13}.Supports(minimum)).To(BeFalse())
+	g.Expect(BrokerAPIVersion{3, 0}.Supports(minimum)).To(BeFalse())
+}
@@ -0,0 +1,33 @@
+package model
+
+const (
+	//OperationInProgress is the state of an asynchronous operation which is not finished yet
+	OperationInProgress = "in progress"
+	//OperationSucceeded is the state of a successfully finished asynchronous operation
+	OperationSucceeded = "succeeded"
+	//OperationFailed is the state of a failed asynchronous operation
+	OperationFailed = "failed"
+)
+
+//LastOperationResponse represents a response to a last_operation call according to OSB-spec
+type LastOperationResponse struct {
+	AdditionalProperties additionalProperties
+	State                string
+	NetworkProfiles      []NetworkProfile
+}
+
+//UnmarshalJSON to LastOperationResponse
+func (lastOperationResponse *LastOperationResponse) UnmarshalJSON(b []byte) error {
+	return lastOperationResponse.AdditionalProperties.UnmarshalJSON(b, map[string]interface{}{
+		"state":            &lastOperationResponse.State,
+		"network_profiles": &lastOperationResponse.NetworkProfiles,
+	})
+}
+
+//MarshalJSON from LastOperationResponse
+func (lastOperationResponse LastOperationResponse) MarshalJSON() ([]byte, error) {
+	return lastOperationResponse.AdditionalProperties.MarshalJSON(map[string]interface{}{
+		"state":            &lastOperationResponse.State,
+		"network_profiles": lastOperationResponse.NetworkProfiles,
+	})
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestLastOperationResponseUnmarshal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var lastOperationResponse LastOperationResponse
+	err := json.Unmarshal([]byte(`{
+		"state" : "succeeded",
+		"description" : "done",
+		"network_profiles": [{
+			"id" : "my-profile-id"
+		}]
+	}`), &lastOperationResponse)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lastOperationResponse.State).To(Equal(OperationSucceeded))
+	g.Expect(string(lastOperationResponse.AdditionalProperties["description"])).To(Equal(`"done"`))
+	g.Expect(lastOperationResponse.NetworkProfiles[0].ID).To(Equal("my-profile-id"))
+}
+
+func TestLastOperationResponseMarshal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lastOperationResponse := LastOperationResponse{
+		AdditionalProperties: map[string]json.RawMessage{
+			"description": json.RawMessage([]byte(`"working"`)),
+		},
+		State: OperationInProgress,
+	}
+	body, err := json.Marshal(lastOperationResponse)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).To(MatchJSON(`{
+		"state" : "in progress",
+		"description" : "working"
+	}`))
+}
@@ -0,0 +1,17 @@
+package model
+
+//UpdateRequest represents an update service instance request according to OSB-spec
+type UpdateRequest struct {
+	AdditionalProperties additionalProperties
+	NetworkProfiles      []NetworkProfile
+}
+
+//UnmarshalJSON to UpdateRequest
+func (updateRequest *UpdateRequest) UnmarshalJSON(b []byte) error {
+	return updateRequest.AdditionalProperties.UnmarshalJSON(b, map[string]interface{}{"network_profiles": &updateRequest.NetworkProfiles})
+}
+
+//MarshalJSON from UpdateRequest
+func (updateRequest UpdateRequest) MarshalJSON() ([]byte, error) {
+	return updateRequest.AdditionalProperties.MarshalJSON(map[string]interface{}{"network_profiles": &updateRequest.NetworkProfiles})
+}
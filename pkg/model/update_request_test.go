@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestUpdateRequestUnmarshal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var updateRequest UpdateRequest
+	err := json.Unmarshal([]byte(`{
+		"plan_id": "new-plan",
+		"network_profiles": [{
+			"id" : "my-profile-id",
+			"data":{
+				"consumer_id": "147"
+			}
+		}]
+	}`), &updateRequest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(updateRequest.AdditionalProperties["plan_id"])).To(Equal(`"new-plan"`))
+	g.Expect(updateRequest.NetworkProfiles[0].ID).To(Equal("my-profile-id"))
+	g.Expect(string(updateRequest.NetworkProfiles[0].Data)).To(MatchJSON(`{"consumer_id": "147"}`))
+}
+
+func TestUpdateRequestMarshal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var updateRequest = UpdateRequest{
+		AdditionalProperties: map[string]json.RawMessage{
+			"plan_id": json.RawMessage([]byte(`"new-plan"`)),
+		},
+		NetworkProfiles: []NetworkProfile{{
+			ID:   "my-profile-id",
+			Data: json.RawMessage([]byte(`{"consumer_id": "147"}`))}}}
+	body, err := json.Marshal(updateRequest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).To(MatchJSON(`{
+		"plan_id": "new-plan",
+		"network_profiles": [{
+			"id" : "my-profile-id",
+			"data":{
+				"consumer_id": "147"
+			}
+		}]
+	}`))
+}
+
+func TestUpdateRequestUnmarshalInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var updateRequest UpdateRequest
+	err := json.Unmarshal([]byte(`{
+		"network_profiles": 666
+	}`), &updateRequest)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,17 @@
+package model
+
+//UpdateResponse represents an update service instance response according to OSB-spec
+type UpdateResponse struct {
+	AdditionalProperties additionalProperties
+	NetworkProfiles      []NetworkProfile
+}
+
+//UnmarshalJSON to UpdateResponse
+func (updateResponse *UpdateResponse) UnmarshalJSON(b []byte) error {
+	return updateResponse.AdditionalProperties.UnmarshalJSON(b, map[string]interface{}{"network_profiles": &updateResponse.NetworkProfiles})
+}
+
+//MarshalJSON from UpdateResponse
+func (updateResponse UpdateResponse) MarshalJSON() ([]byte, error) {
+	return updateResponse.AdditionalProperties.MarshalJSON(map[string]interface{}{"network_profiles": &updateResponse.NetworkProfiles})
+}
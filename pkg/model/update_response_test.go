@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestUpdateResponseUnmarshal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var updateResponse UpdateResponse
+	err := json.Unmarshal([]byte(`{
+		"plan_id": "new-plan",
+		"network_profiles": [{
+			"id" : "my-profile-id",
+			"data":{
+				"consumer_id": "147"
+			}
+		}]
+	}`), &updateResponse)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(updateResponse.AdditionalProperties["plan_id"])).To(Equal(`"new-plan"`))
+	g.Expect(updateResponse.NetworkProfiles[0].ID).To(Equal("my-profile-id"))
+	g.Expect(string(updateResponse.NetworkProfiles[0].Data)).To(MatchJSON(`{"consumer_id": "147"}`))
+}
+
+func TestUpdateResponseMarshal(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var updateResponse = UpdateResponse{
+		AdditionalProperties: map[string]json.RawMessage{
+			"plan_id": json.RawMessage([]byte(`"new-plan"`)),
+		},
+		NetworkProfiles: []NetworkProfile{{
+			ID:   "my-profile-id",
+			Data: json.RawMessage([]byte(`{"consumer_id": "147"}`))}}}
+	body, err := json.Marshal(updateResponse)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(body)).To(MatchJSON(`{
+		"plan_id": "new-plan",
+		"network_profiles": [{
+			"id" : "my-profile-id",
+			"data":{
+				"consumer_id": "147"
+			}
+		}]
+	}`))
+}
+
+func TestUpdateResponseUnmarshalInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var updateResponse UpdateResponse
+	err := json.Unmarshal([]byte(`{
+		"network_profiles": 666
+	}`), &updateResponse)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+)
+
+// postFetchBinding runs the deferred post bind step of an asynchronous binding on its first fetch.
+// Bindings whose istio configuration already exists are adapted without touching the configuration.
+func postFetchBinding(logger requestLogger, configStore ConfigStore, bindID string,
+	postBind func(request model.BindRequest) (*model.BindResponse, error),
+	adaptExisting func() (*model.BindResponse, error)) (*model.BindResponse, error) {
+	request, err := configStore.LoadBindRequest(bindID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return adaptExisting()
+	}
+	logger.Infof("Completing asynchronous binding %s\n", bindID)
+	binding, err := postBind(*request)
+	if err != nil {
+		return nil, err
+	}
+	err = configStore.DeleteBindRequest(bindID)
+	if err != nil {
+		logger.Warnf("Ignoring error during removal of bind request %s: %v\n", bindID, err)
+	}
+	return binding, nil
+}
+
+// postLastOperationSucceeded removes the configuration of an instance whose asynchronous deprovisioning has succeeded
+func postLastOperationSucceeded(logger requestLogger, configStore ConfigStore, instanceID string, postDeprovision func(instanceID string)) bool {
+	if configStore == nil {
+		return false
+	}
+	deprovisioning, err := configStore.IsDeprovisioning(instanceID)
+	if err != nil {
+		logger.Warnf("Unable to check for deprovisioning of instance %s: %v\n", instanceID, err)
+		return false
+	}
+	if deprovisioning {
+		logger.Infof("Completing asynchronous deprovisioning of instance %s\n", instanceID)
+		postDeprovision(instanceID)
+	}
+	return deprovisioning
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"io"
+	istioModel "istio.io/istio/pilot/pkg/model"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	auditProvision        = "provision"
+	auditBind             = "bind"
+	auditUnbind           = "unbind"
+	auditAdaptCredentials = "adapt_credentials"
+
+	auditSuccess = "success"
+	auditFailure = "failure"
+)
+
+//AuditEvent describes a step in the lifecycle of a binding. It never contains credentials.
+type AuditEvent struct {
+	Time           time.Time `json:"time"`
+	Event          string    `json:"event"`
+	RequestID      string    `json:"requestId,omitempty"`
+	InstanceID     string    `json:"instanceId,omitempty"`
+	BindingID      string    `json:"bindingId,omitempty"`
+	ConsumerID     string    `json:"consumerId,omitempty"`
+	ProviderID     string    `json:"providerId,omitempty"`
+	NetworkProfile string    `json:"networkProfile,omitempty"`
+	IstioObjects   []string  `json:"istioObjects,omitempty"`
+	Outcome        string    `json:"outcome"`
+	// Reason is the error code of a failure, the description is omitted as it could quote a response of the broker
+	Reason string `json:"reason,omitempty"`
+}
+
+//AuditLog receives the audit events of the interceptors
+type AuditLog interface {
+	Record(event AuditEvent)
+}
+
+type jsonAuditLog struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+//NewJSONAuditLog creates an AuditLog which writes each event as one line of JSON
+func NewJSONAuditLog(writer io.Writer) AuditLog {
+	return &jsonAuditLog{encoder: json.NewEncoder(writer)}
+}
+
+//OpenAuditLog creates an AuditLog for the given sink, which is either 'stdout' or a file the events are appended to
+func OpenAuditLog(sink string) (AuditLog, error) {
+	if sink == "stdout" {
+		return NewJSONAuditLog(os.Stdout), nil
+	}
+	file, err := os.OpenFile(sink, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONAuditLog(file), nil
+}
+
+func (a *jsonAuditLog) Record(event AuditEvent) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	err := a.encoder.Encode(event)
+	if err != nil {
+		newRequestLogger(event.RequestID).Errorf("Unable to write audit event %s of binding %s: %v\n", event.Event, event.BindingID, err)
+	}
+}
+
+type instanceIDKey struct{}
+
+func withInstanceID(ctx context.Context, instanceID string) context.Context {
+	return context.WithValue(ctx, instanceIDKey{}, instanceID)
+}
+
+// recordAudit completes the event with the outcome and the ids of the request in the context
+func recordAudit(ctx context.Context, auditLog AuditLog, event AuditEvent, err error) {
+	if auditLog == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	if ctx != nil {
+		event.RequestID = requestIDFrom(ctx)
+		if event.InstanceID == "" {
+			event.InstanceID, _ = ctx.Value(instanceIDKey{}).(string)
+		}
+	}
+	event.Outcome = auditSuccess
+	if err != nil {
+		event.Outcome = auditFailure
+		event.Reason = model.HTTPErrorFromError(err, http.StatusInternalServerError).ErrorMsg
+	}
+	auditLog.Record(event)
+}
+
+func istioObjectNames(configs []istioModel.Config) []string {
+	names := make([]string, 0, len(configs))
+	for _, config := range configs {
+		names = append(names, config.Type+"/"+config.Name)
+	}
+	return names
+}
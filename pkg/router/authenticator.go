@@ -0,0 +1,106 @@
+package router
+
+import (
+	"crypto/subtle"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Authenticator checks the credentials of an incoming request
+type Authenticator interface {
+	Authenticate(request *http.Request) (bool, error)
+}
+
+type basicAuthenticator struct {
+	secret *reloadingFile
+}
+
+//NewBasicAuthenticator creates an Authenticator which accepts the basic auth credentials listed as <user>:<password>
+//lines in the given file. The file is reloaded whenever it changes, e.g. after the rotation of a mounted secret.
+func NewBasicAuthenticator(secretFile string) Authenticator {
+	return &basicAuthenticator{&reloadingFile{path: secretFile}}
+}
+
+func (a *basicAuthenticator) Authenticate(request *http.Request) (bool, error) {
+	user, password, ok := request.BasicAuth()
+	if !ok {
+		return false, nil
+	}
+	credentials, err := a.secret.lines()
+	if err != nil {
+		return false, err
+	}
+	return containsSecret(credentials, user+":"+password), nil
+}
+
+type bearerTokenAuthenticator struct {
+	tokens *reloadingFile
+}
+
+//NewBearerTokenAuthenticator creates an Authenticator which accepts the bearer tokens listed line by line in the
+//given file. The file is reloaded whenever it changes.
+func NewBearerTokenAuthenticator(tokenFile string) Authenticator {
+	return &bearerTokenAuthenticator{&reloadingFile{path: tokenFile}}
+}
+
+func (a *bearerTokenAuthenticator) Authenticate(request *http.Request) (bool, error) {
+	authorization := request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false, nil
+	}
+	tokens, err := a.tokens.lines()
+	if err != nil {
+		return false, err
+	}
+	return containsSecret(tokens, strings.TrimPrefix(authorization, "Bearer ")), nil
+}
+
+func containsSecret(secrets []string, candidate string) bool {
+	found := false
+	for _, secret := range secrets {
+		// compare all entries in constant time to not leak which prefix matched
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(candidate)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
+
+type reloadingFile struct {
+	path    string
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	content []string
+}
+
+func (f *reloadingFile) lines() ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.content != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.content, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]string, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			content = append(content, line)
+		}
+	}
+	f.content = content
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return content, nil
+}
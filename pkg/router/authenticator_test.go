@@ -0,0 +1,93 @@
+package router
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeSecretFile(g *GomegaWithT, name string, content string) string {
+	fileName := path.Join(os.TempDir(), name)
+	err := ioutil.WriteFile(fileName, []byte(content), 0600)
+	g.Expect(err).NotTo(HaveOccurred())
+	return fileName
+}
+
+func TestBasicAuthenticator(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := writeSecretFile(g, "basic-auth-secret", "admin:secret\nbroker:other-secret\n")
+	defer os.Remove(fileName)
+	authenticator := NewBasicAuthenticator(fileName)
+
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", nil)
+	ok, err := authenticator.Authenticate(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	request.SetBasicAuth("broker", "other-secret")
+	ok, err = authenticator.Authenticate(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+
+	request.SetBasicAuth("broker", "secret")
+	ok, err = authenticator.Authenticate(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestBasicAuthenticatorReloadsRotatedSecret(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := writeSecretFile(g, "basic-auth-secret", "admin:secret")
+	defer os.Remove(fileName)
+	authenticator := NewBasicAuthenticator(fileName)
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", nil)
+	request.SetBasicAuth("admin", "secret")
+
+	ok, _ := authenticator.Authenticate(request)
+	g.Expect(ok).To(BeTrue())
+
+	writeSecretFile(g, "basic-auth-secret", "admin:rotated-secret")
+	ok, _ = authenticator.Authenticate(request)
+	g.Expect(ok).To(BeFalse())
+
+	request.SetBasicAuth("admin", "rotated-secret")
+	ok, _ = authenticator.Authenticate(request)
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestBasicAuthenticatorMissingSecret(t *testing.T) {
+	g := NewGomegaWithT(t)
+	authenticator := NewBasicAuthenticator("/invalid-directory/basic-auth-secret")
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", nil)
+	request.SetBasicAuth("admin", "secret")
+
+	_, err := authenticator.Authenticate(request)
+
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestBearerTokenAuthenticator(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := writeSecretFile(g, "bearer-tokens", "token-1\ntoken-2")
+	defer os.Remove(fileName)
+	authenticator := NewBearerTokenAuthenticator(fileName)
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", nil)
+
+	request.Header.Set("Authorization", "Bearer token-2")
+	ok, err := authenticator.Authenticate(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+
+	request.Header.Set("Authorization", "Bearer token-3")
+	ok, err = authenticator.Authenticate(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	request.SetBasicAuth("token-1", "")
+	ok, err = authenticator.Authenticate(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"context"
+	"sync"
+)
+
+//BindingLock serializes concurrent operations on the same binding. The context carries the id of the request for
+//the log.
+type BindingLock interface {
+	TryLock(ctx context.Context, bindingID string) (bool, error)
+	Unlock(ctx context.Context, bindingID string) error
+}
+
+type inProcessBindingLock struct {
+	mutex  sync.Mutex
+	locked map[string]bool
+}
+
+//NewInProcessBindingLock creates a BindingLock which is only effective within this process
+func NewInProcessBindingLock() BindingLock {
+	return &inProcessBindingLock{locked: make(map[string]bool)}
+}
+
+func (l *inProcessBindingLock) TryLock(ctx context.Context, bindingID string) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.locked[bindingID] {
+		return false, nil
+	}
+	l.locked[bindingID] = true
+	return true, nil
+}
+
+func (l *inProcessBindingLock) Unlock(ctx context.Context, bindingID string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.locked, bindingID)
+	return nil
+}
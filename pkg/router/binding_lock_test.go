@@ -0,0 +1,36 @@
+package router
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestInProcessBindingLock(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock := NewInProcessBindingLock()
+
+	locked, err := lock.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+
+	locked, err = lock.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeFalse())
+
+	locked, err = lock.TryLock(context.Background(), "456")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+
+	g.Expect(lock.Unlock(context.Background(), "123")).To(Succeed())
+	locked, err = lock.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+}
+
+func TestInProcessBindingLockUnlockWithoutLock(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lock := NewInProcessBindingLock()
+
+	g.Expect(lock.Unlock(context.Background(), "123")).To(Succeed())
+}
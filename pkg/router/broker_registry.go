@@ -0,0 +1,188 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	brokerPathPrefix = "/v1/osb/"
+	brokerProxyKey   = "istio-broker-proxy-broker"
+)
+
+//BrokerConfig contains the settings of a single broker behind the proxy, unset ones are inherited from the global config
+type BrokerConfig struct {
+	ForwardURL        string `json:"forwardUrl"`
+	SkipVerifyTLS     *bool  `json:"skipVerifyTLS"`
+	ServiceNamePrefix string `json:"serviceNamePrefix"`
+	NetworkProfile    string `json:"networkProfile"`
+	CACertFile        string `json:"caCertFile"`
+	ClientCertFile    string `json:"clientCertFile"`
+	ClientKeyFile     string `json:"clientKeyFile"`
+	ServerName        string `json:"serverName"`
+	// UpstreamCredentialsFile or UpstreamCredentialsEnv contain the <user>:<password> of this broker
+	UpstreamCredentialsFile string `json:"upstreamCredentialsFile"`
+	UpstreamCredentialsEnv  string `json:"upstreamCredentialsEnv"`
+}
+
+//LoadBrokerRegistry reads the broker settings keyed by broker id from a json file
+func LoadBrokerRegistry(fileName string) (map[string]BrokerConfig, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var brokers map[string]BrokerConfig
+	err = json.Unmarshal(content, &brokers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker registry %s: %v", fileName, err)
+	}
+	for brokerID, broker := range brokers {
+		if broker.ForwardURL == "" {
+			return nil, fmt.Errorf("invalid broker registry %s: no forwardUrl for broker %s", fileName, brokerID)
+		}
+	}
+	return brokers, nil
+}
+
+type brokerProxies struct {
+	defaultProxy *osbProxy
+	brokers      map[string]*osbProxy
+}
+
+func newBrokerProxies(defaultProxy *osbProxy, interceptor ServiceBrokerInterceptor, routerConfig Config) *brokerProxies {
+	proxies := brokerProxies{defaultProxy, make(map[string]*osbProxy)}
+	for brokerID, broker := range routerConfig.Brokers {
+		brokerConfig := routerConfig
+		brokerConfig.ForwardURL = broker.ForwardURL
+		if broker.SkipVerifyTLS != nil {
+			brokerConfig.SkipVerifyTLS = *broker.SkipVerifyTLS
+		}
+		if broker.CACertFile != "" {
+			brokerConfig.CACertFile = broker.CACertFile
+		}
+		if broker.ClientCertFile != "" {
+			brokerConfig.ClientCertFile = broker.ClientCertFile
+			brokerConfig.ClientKeyFile = broker.ClientKeyFile
+		}
+		if broker.ServerName != "" {
+			brokerConfig.ServerName = broker.ServerName
+		}
+		if broker.UpstreamCredentialsFile != "" {
+			brokerConfig.UpstreamCredentials = NewFileUpstreamCredentials(broker.UpstreamCredentialsFile)
+		} else if broker.UpstreamCredentialsEnv != "" {
+			brokerConfig.UpstreamCredentials = NewEnvUpstreamCredentials(broker.UpstreamCredentialsEnv)
+		}
+		proxy := newOsbProxy(interceptorForBroker(interceptor, broker), brokerConfig)
+		proxies.brokers[brokerID] = &proxy
+	}
+	return &proxies
+}
+
+func interceptorForBroker(interceptor ServiceBrokerInterceptor, broker BrokerConfig) ServiceBrokerInterceptor {
+	switch brokerInterceptor := interceptor.(type) {
+	case *ConsumerInterceptor:
+		return interceptorForBroker(*brokerInterceptor, broker)
+	case *ProducerInterceptor:
+		return interceptorForBroker(*brokerInterceptor, broker)
+	case ConsumerInterceptor:
+		if broker.ServiceNamePrefix != "" {
+			brokerInterceptor.ServiceNamePrefix = broker.ServiceNamePrefix
+		}
+		if broker.NetworkProfile != "" {
+			brokerInterceptor.NetworkProfile = broker.NetworkProfile
+		}
+		return brokerInterceptor
+	case ProducerInterceptor:
+		if broker.ServiceNamePrefix != "" {
+			brokerInterceptor.ServiceNamePrefix = broker.ServiceNamePrefix
+		}
+		if broker.NetworkProfile != "" {
+			brokerInterceptor.NetworkProfile = broker.NetworkProfile
+		}
+		return brokerInterceptor
+	default:
+		return interceptor
+	}
+}
+
+// resolve returns the proxy responsible for the given broker and strips the broker prefix from the request path,
+// as the registered brokers are addressed directly. Without a registry everything goes to the default proxy.
+func (proxies *brokerProxies) resolve(ctx *gin.Context, brokerID string) (*osbProxy, bool) {
+	if len(proxies.brokers) == 0 {
+		return proxies.defaultProxy, true
+	}
+	proxy, ok := proxies.brokers[brokerID]
+	if !ok {
+		httpError(ctx, model.HTTPError{ErrorMsg: "UnknownBroker", Description: fmt.Sprintf("Broker %s is not registered", brokerID), StatusCode: http.StatusNotFound}, http.StatusNotFound)
+		return nil, false
+	}
+	ctx.Request.URL.Path = strings.TrimPrefix(ctx.Request.URL.Path, brokerPathPrefix+brokerID)
+	return proxy, true
+}
+
+func (proxies *brokerProxies) selectBroker(ctx *gin.Context) {
+	proxy, ok := proxies.resolve(ctx, ctx.Params.ByName("broker_id"))
+	if !ok {
+		return
+	}
+	ctx.Set(brokerProxyKey, proxy)
+	ctx.Next()
+}
+
+func (proxies *brokerProxies) handle(handler func(osbProxy, *gin.Context)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		proxy := *proxies.defaultProxy
+		if brokerProxy, ok := ctx.Get(brokerProxyKey); ok {
+			proxy = *brokerProxy.(*osbProxy)
+		}
+		if version, ok := ctx.Get(brokerAPIVersionKey); ok {
+			if interceptor, ok := proxy.interceptor.(BrokerAPIVersionAware); ok {
+				proxy.interceptor = interceptor.WithBrokerAPIVersion(version.(model.BrokerAPIVersion))
+			}
+		}
+		if instanceID := ctx.Params.ByName("instance_id"); instanceID != "" {
+			ctx.Request = ctx.Request.WithContext(withInstanceID(ctx.Request.Context(), instanceID))
+		}
+		handler(proxy, ctx)
+	}
+}
+
+// metricsLabel returns the broker id for the metrics, ids which are not registered would let the number of series grow
+// with every request
+func (proxies *brokerProxies) metricsLabel(brokerID string) string {
+	if brokerID == "" || len(proxies.brokers) == 0 {
+		return defaultBrokerID
+	}
+	if _, ok := proxies.brokers[brokerID]; !ok {
+		return unknownBrokerID
+	}
+	return brokerID
+}
+
+// circuitBreakerStates maps the default proxy and the broker ids to the states of their circuit breakers
+func (proxies *brokerProxies) circuitBreakerStates() map[string]string {
+	states := map[string]string{defaultBrokerID: proxies.defaultProxy.breaker.State()}
+	for brokerID, proxy := range proxies.brokers {
+		states[brokerID] = proxy.breaker.State()
+	}
+	return states
+}
+
+func (proxies *brokerProxies) forward(ctx *gin.Context) {
+	path := ctx.Request.URL.Path
+	if !strings.HasPrefix(path, brokerPathPrefix) {
+		proxies.defaultProxy.forward(ctx)
+		return
+	}
+	brokerID := strings.SplitN(strings.TrimPrefix(path, brokerPathPrefix), "/", 2)[0]
+	proxy, ok := proxies.resolve(ctx, brokerID)
+	if !ok {
+		return
+	}
+	proxy.forward(ctx)
+}
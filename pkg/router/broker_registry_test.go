@@ -0,0 +1,100 @@
+package router
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestLoadBrokerRegistry(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := path.Join(os.TempDir(), "broker-registry.json")
+	defer os.Remove(fileName)
+	err := ioutil.WriteFile(fileName, []byte(`{"broker-a": {"forwardUrl": "https://broker-a.xx", "skipVerifyTLS": true, "serviceNamePrefix": "a-", "networkProfile": "urn:local.test:a"}}`), 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	brokers, err := LoadBrokerRegistry(fileName)
+
+	skipVerifyTLS := true
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(brokers).To(Equal(map[string]BrokerConfig{
+		"broker-a": {ForwardURL: "https://broker-a.xx", SkipVerifyTLS: &skipVerifyTLS, ServiceNamePrefix: "a-", NetworkProfile: "urn:local.test:a"},
+	}))
+}
+
+func TestLoadBrokerRegistryWithoutForwardURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := path.Join(os.TempDir(), "broker-registry.json")
+	defer os.Remove(fileName)
+	err := ioutil.WriteFile(fileName, []byte(`{"broker-a": {"serviceNamePrefix": "a-"}}`), 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = LoadBrokerRegistry(fileName)
+
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("broker-a"))
+}
+
+func TestLoadBrokerRegistryMissingFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := LoadBrokerRegistry("/invalid-directory/broker-registry.json")
+
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestInterceptorForBroker(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := &ConsumerInterceptor{ConsumerID: "consumer", ServiceNamePrefix: "default-", NetworkProfile: "urn:local.test:default"}
+
+	brokerInterceptor := interceptorForBroker(interceptor, BrokerConfig{NetworkProfile: "urn:local.test:a"})
+
+	g.Expect(brokerInterceptor).To(Equal(ConsumerInterceptor{ConsumerID: "consumer", ServiceNamePrefix: "default-", NetworkProfile: "urn:local.test:a"}))
+	g.Expect(interceptor.NetworkProfile).To(Equal("urn:local.test:default"))
+}
+
+func TestBrokerProxiesInheritTLSSettings(t *testing.T) {
+	g := NewGomegaWithT(t)
+	skipVerifyTLS := false
+	routerConfig := Config{SkipVerifyTLS: true, ServerName: "broker.istio.test", Brokers: map[string]BrokerConfig{
+		"broker-a": {ForwardURL: "https://broker-a.xx"},
+		"broker-b": {ForwardURL: "https://broker-b.xx", SkipVerifyTLS: &skipVerifyTLS, ServerName: "broker-b.istio.test"},
+	}}
+	routerConfig.HTTPClientFactory = func(tr *http.Transport) *http.Client {
+		return &http.Client{Transport: tr}
+	}
+
+	proxies := newBrokerProxies(nil, noOpInterceptor{}, routerConfig)
+
+	g.Expect(proxies.brokers["broker-a"].config.SkipVerifyTLS).To(BeTrue())
+	g.Expect(proxies.brokers["broker-a"].config.ServerName).To(Equal("broker.istio.test"))
+	g.Expect(proxies.brokers["broker-b"].config.SkipVerifyTLS).To(BeFalse())
+	g.Expect(proxies.brokers["broker-b"].config.ServerName).To(Equal("broker-b.istio.test"))
+}
+
+func TestBrokerProxiesUseOwnUpstreamCredentials(t *testing.T) {
+	g := NewGomegaWithT(t)
+	os.Setenv("ISTIO_BROKER_PROXY_TEST_BROKER_A", "user-a:password-a")
+	defer os.Unsetenv("ISTIO_BROKER_PROXY_TEST_BROKER_A")
+	os.Setenv("ISTIO_BROKER_PROXY_TEST_GLOBAL", "user:password")
+	defer os.Unsetenv("ISTIO_BROKER_PROXY_TEST_GLOBAL")
+	routerConfig := Config{UpstreamCredentials: NewEnvUpstreamCredentials("ISTIO_BROKER_PROXY_TEST_GLOBAL"), Brokers: map[string]BrokerConfig{
+		"broker-a": {ForwardURL: "https://broker-a.xx", UpstreamCredentialsEnv: "ISTIO_BROKER_PROXY_TEST_BROKER_A"},
+		"broker-b": {ForwardURL: "https://broker-b.xx"},
+	}}
+	routerConfig.HTTPClientFactory = func(tr *http.Transport) *http.Client {
+		return &http.Client{Transport: tr}
+	}
+
+	proxies := newBrokerProxies(nil, noOpInterceptor{}, routerConfig)
+
+	authorization, err := proxies.brokers["broker-a"].config.UpstreamCredentials.Authorization()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(authorization).To(Equal("Basic dXNlci1hOnBhc3N3b3JkLWE="))
+	authorization, err = proxies.brokers["broker-b"].config.UpstreamCredentials.Authorization()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(authorization).To(Equal("Basic dXNlcjpwYXNzd29yZA=="))
+}
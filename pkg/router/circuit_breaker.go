@@ -0,0 +1,165 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"istio.io/istio/pkg/log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+	circuitDisabled = "disabled"
+
+	circuitOpenError = "CircuitBreakerOpen"
+)
+
+//CircuitBreakerConfig contains the thresholds of the circuit breaker for the calls to the ForwardURL
+type CircuitBreakerConfig struct {
+	// FailureThreshold of consecutive connection errors, timeouts or 5xx responses opens the circuit, zero disables it
+	FailureThreshold int
+	// OpenTimeout is the time requests fail fast before the circuit becomes half-open and lets a trial call through
+	OpenTimeout time.Duration
+	// SuccessThreshold of successful trial calls closes a half-open circuit, zero is treated as one
+	SuccessThreshold int
+}
+
+type circuitBreaker struct {
+	config    CircuitBreakerConfig
+	target    string
+	mutex     sync.Mutex
+	state     string
+	failures  int
+	successes int
+	openedAt  time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig, target string) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = 1
+	}
+	return &circuitBreaker{config: config, target: target, state: circuitClosed, now: time.Now}
+}
+
+// allow fails fast while the circuit is open and lets only one trial call at a time through while it is half-open
+func (b *circuitBreaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == circuitOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.transition(circuitHalfOpen)
+	}
+	if b.state == circuitOpen || (b.state == circuitHalfOpen && b.trial) {
+		return model.HTTPError{ErrorMsg: circuitOpenError, Description: fmt.Sprintf("The circuit breaker for %s is open", b.target), StatusCode: http.StatusServiceUnavailable}
+	}
+	if b.state == circuitHalfOpen {
+		b.trial = true
+	}
+	return nil
+}
+
+// record counts the outcome of an allowed call
+func (b *circuitBreaker) record(success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	halfOpen := b.state == circuitHalfOpen
+	b.trial = false
+	switch {
+	case success && halfOpen:
+		b.successes++
+		if b.successes >= b.config.SuccessThreshold {
+			b.transition(circuitClosed)
+		}
+	case success:
+		b.failures = 0
+	case halfOpen:
+		b.transition(circuitOpen)
+	default:
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.transition(circuitOpen)
+		}
+	}
+}
+
+// release ends an allowed call without counting it, e.g. because the incoming request was cancelled
+func (b *circuitBreaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) transition(state string) {
+	log.Infof("Circuit breaker for %s changes from %s to %s\n", b.target, b.state, state)
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	if state == circuitOpen {
+		b.openedAt = b.now()
+	}
+}
+
+//State returns the state of the circuit for the health endpoint, a nil circuitBreaker is disabled
+func (b *circuitBreaker) State() string {
+	if b == nil {
+		return circuitDisabled
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == circuitOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		return circuitHalfOpen
+	}
+	return b.state
+}
+
+type circuitBreakerTransport struct {
+	breaker *circuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *circuitBreakerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	err := t.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	response, err := t.next.RoundTrip(request)
+	if err != nil && request.Context().Err() == context.Canceled {
+		t.breaker.release()
+	} else {
+		t.breaker.record(err == nil && response.StatusCode < 500)
+	}
+	return response, err
+}
+
+// withCircuitBreaker returns a copy of the client whose calls pass the given circuit breaker
+func withCircuitBreaker(client *http.Client, breaker *circuitBreaker) *http.Client {
+	if breaker == nil {
+		return client
+	}
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	wrapped := *client
+	wrapped.Transport = &circuitBreakerTransport{breaker, next}
+	return &wrapped
+}
+
+// isCircuitOpen unwraps the error of the circuit breaker from the url.Error of the http.Client
+func isCircuitOpen(err error) (model.HTTPError, bool) {
+	if urlError, ok := err.(*url.Error); ok {
+		err = urlError.Err
+	}
+	httpError, ok := err.(model.HTTPError)
+	return httpError, ok && httpError.ErrorMsg == circuitOpenError
+}
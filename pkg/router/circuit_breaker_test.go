@@ -0,0 +1,104 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCircuitBreaker(now *time.Time) *circuitBreaker {
+	breaker := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, SuccessThreshold: 2}, "http://broker")
+	breaker.now = func() time.Time { return *now }
+	return breaker
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Now()
+	breaker := newTestCircuitBreaker(&now)
+
+	breaker.record(false)
+	breaker.record(true)
+	breaker.record(false)
+	g.Expect(breaker.State()).To(Equal(circuitClosed))
+	breaker.record(false)
+	g.Expect(breaker.State()).To(Equal(circuitOpen))
+
+	err := breaker.allow()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.(model.HTTPError).StatusCode).To(Equal(http.StatusServiceUnavailable))
+}
+
+func TestHalfOpenCircuitBreakerLetsOneTrialThrough(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Now()
+	breaker := newTestCircuitBreaker(&now)
+	breaker.record(false)
+	breaker.record(false)
+
+	now = now.Add(time.Minute)
+	g.Expect(breaker.State()).To(Equal(circuitHalfOpen))
+	g.Expect(breaker.allow()).To(Succeed())
+	g.Expect(breaker.allow()).NotTo(Succeed())
+	breaker.record(true)
+	g.Expect(breaker.State()).To(Equal(circuitHalfOpen))
+	g.Expect(breaker.allow()).To(Succeed())
+	breaker.record(true)
+
+	g.Expect(breaker.State()).To(Equal(circuitClosed))
+}
+
+func TestFailedTrialOpensCircuitBreakerAgain(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Now()
+	breaker := newTestCircuitBreaker(&now)
+	breaker.record(false)
+	breaker.record(false)
+
+	now = now.Add(time.Minute)
+	g.Expect(breaker.allow()).To(Succeed())
+	breaker.record(false)
+
+	g.Expect(breaker.State()).To(Equal(circuitOpen))
+	g.Expect(breaker.allow()).NotTo(Succeed())
+}
+
+func TestOpenCircuitFailsFastAndShowsInHealth(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub := newHandlerStub(http.StatusServiceUnavailable, []byte(`{}`))
+	calls := 0
+	handlerStub.handler = func([]byte) []byte {
+		calls++
+		return []byte(`{}`)
+	}
+	server, routerConfig := injectClientStub(handlerStub)
+	defer server.Close()
+	routerConfig.CircuitBreaker = CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute}
+	router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+
+	codes := make([]int, 0)
+	for i := 0; i < 3; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", bytes.NewReader(make([]byte, 0)))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		codes = append(codes, response.Code)
+		if i == 2 {
+			var err model.HTTPError
+			json.Unmarshal(response.Body.Bytes(), &err)
+			g.Expect(err.ErrorMsg).To(Equal(circuitOpenError))
+		}
+	}
+	g.Expect(codes).To(Equal([]int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}))
+	g.Expect(calls).To(Equal(2))
+
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/health", bytes.NewReader(make([]byte, 0)))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	g.Expect(response.Code).To(Equal(http.StatusOK))
+	g.Expect(response.Body.String()).To(MatchJSON(`{"status": "UP", "circuitBreakers": {"default": "open"}}`))
+}
@@ -1,13 +1,60 @@
 package router
 
 import (
-	"istio.io/istio/pilot/pkg/model"
+	"context"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	istioModel "istio.io/istio/pilot/pkg/model"
 	"k8s.io/api/core/v1"
+	"net/http"
 )
 
 //ConfigStore encapsulates functions to modify istio config
 type ConfigStore interface {
-	CreateService(bindingID string, service *v1.Service) (*v1.Service, error)
-	CreateIstioConfig(bindingID string, config []model.Config) error
+	CreateService(instanceID string, bindingID string, service *v1.Service) (*v1.Service, error)
+	GetService(bindingID string, name string) (*v1.Service, error)
+	// DeleteService removes a service of the binding, a missing one is no error
+	DeleteService(bindingID string, name string) error
+	// ApplyIstioConfig applies the configurations as one batch: either all of them are created or reused, or the ones
+	// created by the call are removed again and the error of the failed one is returned
+	ApplyIstioConfig(instanceID string, bindingID string, config []istioModel.Config) error
 	DeleteBinding(bindingID string) error
+	DeleteInstance(instanceID string) error
+	StoreBindRequest(instanceID string, bindingID string, request model.BindRequest) error
+	LoadBindRequest(bindingID string) (*model.BindRequest, error)
+	DeleteBindRequest(bindingID string) error
+	// StoreDeprovision records an asynchronous deprovisioning until DeleteInstance removes the instance
+	StoreDeprovision(instanceID string) error
+	// IsDeprovisioning checks for an asynchronous deprovisioning recorded by StoreDeprovision
+	IsDeprovisioning(instanceID string) (bool, error)
+	// CountBindings returns the number of bindings with istio configuration, excluding the producer's own route
+	CountBindings() (int, error)
+	// Health returns an error if the store is unable to read or write the configuration
+	Health() error
+}
+
+// newConflictError reports objects which already exist with different parameters
+func newConflictError(format string, args ...interface{}) error {
+	return model.HTTPError{ErrorMsg: "Conflict", Description: fmt.Sprintf(format, args...), StatusCode: http.StatusConflict}
+}
+
+func isConflict(err error) bool {
+	return model.HTTPErrorFromError(err, 0).StatusCode == http.StatusConflict
+}
+
+// isBinding excludes the configuration of the producer itself, which is stored like a binding
+func isBinding(bindingID string) bool {
+	return bindingID != "" && bindingID != producerBindingID
+}
+
+// contextConfigStore is implemented by stores which log with the id of the request in the context
+type contextConfigStore interface {
+	withContext(ctx context.Context) ConfigStore
+}
+
+func configStoreWithContext(ctx context.Context, configStore ConfigStore) ConfigStore {
+	if contextStore, ok := configStore.(contextConfigStore); ok {
+		return contextStore.withContext(ctx)
+	}
+	return configStore
 }
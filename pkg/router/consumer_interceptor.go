@@ -1,12 +1,12 @@
 package router
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Peripli/istio-broker-proxy/pkg/config"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
-	"istio.io/istio/pkg/log"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"net/http"
@@ -23,20 +23,89 @@ type ConsumerInterceptor struct {
 	ConfigStore       ConfigStore
 	ServiceNamePrefix string
 	NetworkProfile    string
+	BrokerAPIVersion  model.BrokerAPIVersion
+	// AuditLog records provisions, bindings, unbindings and credential adaptations, nil disables the audit
+	AuditLog AuditLog
+	logger   requestLogger
+	ctx      context.Context
 }
+//WithBrokerAPIVersion see interface definition
+func (c ConsumerInterceptor) WithBrokerAPIVersion(version model.BrokerAPIVersion) ServiceBrokerInterceptor {
+	c.BrokerAPIVersion = version
+	return c
+}
+
+//WithContext see interface definition
+func (c ConsumerInterceptor) WithContext(ctx context.Context) ServiceBrokerInterceptor {
+	c.ConfigStore = newTracedConfigStore(ctx, c.ConfigStore)
+	c.logger = requestLog(ctx)
+	c.ctx = ctx
+	return c
+}
+
 //PreProvision see interface definition
 func (c ConsumerInterceptor) PreProvision(request model.ProvisionRequest) (*model.ProvisionRequest, error) {
-	if len(request.NetworkProfiles) != 0 {
-		return nil, model.HTTPError{ErrorMsg: "InvalidNetworkProfile", Description: "Non-empty NetworkProfile" , StatusCode: http.StatusBadRequest}
+	networkProfiles, err := c.addNetworkProfile(request.NetworkProfiles)
+	if err != nil {
+		return nil, err
 	}
-	request.NetworkProfiles = []model.NetworkProfile{{ID: c.NetworkProfile}}
+	request.NetworkProfiles = networkProfiles
 	return &request, nil
 }
+
+func (c ConsumerInterceptor) addNetworkProfile(profiles []model.NetworkProfile) ([]model.NetworkProfile, error) {
+	if len(profiles) != 0 {
+		return nil, model.HTTPError{ErrorMsg: "InvalidNetworkProfile", Description: "Non-empty NetworkProfile" , StatusCode: http.StatusBadRequest}
+	}
+	return []model.NetworkProfile{{ID: c.NetworkProfile}}, nil
+}
 //PostProvision see interface definition
 func (c ConsumerInterceptor) PostProvision(request model.ProvisionRequest, response model.ProvisionResponse) (*model.ProvisionResponse, error) {
+	err := c.checkProducerNetworkProfiles(response.NetworkProfiles)
+	c.audit(AuditEvent{Event: auditProvision}, err)
+	if err != nil {
+		return nil, err
+	}
+	response.NetworkProfiles = make([]model.NetworkProfile,0)
+	return &response, nil
+}
+
+//PreUpdate see interface definition
+func (c ConsumerInterceptor) PreUpdate(request model.UpdateRequest) (*model.UpdateRequest, error) {
+	networkProfiles, err := c.addNetworkProfile(request.NetworkProfiles)
+	if err != nil {
+		return nil, err
+	}
+	request.NetworkProfiles = networkProfiles
+	return &request, nil
+}
+
+//PostUpdate see interface definition
+func (c ConsumerInterceptor) PostUpdate(request model.UpdateRequest, response model.UpdateResponse) (*model.UpdateResponse, error) {
+	err := c.checkProducerNetworkProfiles(response.NetworkProfiles)
+	if err != nil {
+		return nil, err
+	}
+	response.NetworkProfiles = make([]model.NetworkProfile, 0)
+	return &response, nil
+}
+
+//PostLastOperation see interface definition
+func (c ConsumerInterceptor) PostLastOperation(response model.LastOperationResponse) (*model.LastOperationResponse, error) {
+	if response.State == model.OperationSucceeded {
+		err := c.checkProducerNetworkProfiles(response.NetworkProfiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	response.NetworkProfiles = nil
+	return &response, nil
+}
+
+func (c ConsumerInterceptor) checkProducerNetworkProfiles(profiles []model.NetworkProfile) error {
 	matched := 0
 	unmatched := 0
-	for _, profile := range response.NetworkProfiles{
+	for _, profile := range profiles {
 		if profile.ID == c.NetworkProfile {
 			matched++
 		} else {
@@ -45,11 +114,10 @@ func (c ConsumerInterceptor) PostProvision(request model.ProvisionRequest, respo
 	}
 
 	if matched == 0 || unmatched != 0 {
-		networkProfiles, _ := json.Marshal(response.NetworkProfiles)
-		return nil, model.HTTPError{ErrorMsg: "InvalidProducerNetworkProfile", Description: "NetworkProfile was not found or is invalid: " + string(networkProfiles), StatusCode: http.StatusInternalServerError}
+		networkProfiles, _ := json.Marshal(profiles)
+		return model.HTTPError{ErrorMsg: "InvalidProducerNetworkProfile", Description: "NetworkProfile was not found or is invalid: " + string(networkProfiles), StatusCode: http.StatusInternalServerError}
 	}
-	response.NetworkProfiles = make([]model.NetworkProfile,0)
-	return &response, nil
+	return nil
 }
 
 var _ ServiceBrokerInterceptor = &ConsumerInterceptor{}
@@ -65,13 +133,63 @@ func (c ConsumerInterceptor) PreBind(request model.BindRequest) (*model.BindRequ
 }
 
 //PostBind see interface definition
-func (c ConsumerInterceptor) PostBind(request model.BindRequest, response model.BindResponse, bindID string,
+func (c ConsumerInterceptor) PostBind(request model.BindRequest, response model.BindResponse, instanceID string, bindID string,
 	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error) {
+	endCleanupCondition := func(index int, err error) bool {
+		return index >= len(response.NetworkData.Data.Endpoints)
+	}
+
+	var istioObjects []string
+	binding, err := c.adaptBindResponse(response, bindID, adapt, func(index int, endpoint model.Endpoint) (string, error) {
+		clusterIP, objects, err := createIstioObjects(c.logger, c.ConfigStore, instanceID, bindID, serviceName(index, bindID), endpoint, response.NetworkData.Data.ProviderID)
+		istioObjects = append(istioObjects, objects...)
+		return clusterIP, err
+	})
+	if err != nil {
+		istioObjects = nil
+	}
+	c.audit(AuditEvent{Event: auditBind, InstanceID: instanceID, BindingID: bindID, ProviderID: response.NetworkData.Data.ProviderID, IstioObjects: istioObjects}, err)
+	if err != nil {
+		// a conflict means the objects belong to an existing binding which must stay intact
+		if !isConflict(err) {
+			c.cleanUpConfig(bindID, endCleanupCondition)
+		}
+		return nil, err
+	}
+	return binding, nil
+}
+
+//PostAsyncBind see interface definition
+func (c ConsumerInterceptor) PostAsyncBind(request model.BindRequest, instanceID string, bindID string) error {
+	return c.ConfigStore.StoreBindRequest(instanceID, bindID, request)
+}
+
+//PostFetchBinding see interface definition
+func (c ConsumerInterceptor) PostFetchBinding(response model.BindResponse, instanceID string, bindID string,
+	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error) {
+	return postFetchBinding(c.logger, c.ConfigStore, bindID,
+		func(request model.BindRequest) (*model.BindResponse, error) {
+			return c.PostBind(request, response, instanceID, bindID, adapt)
+		},
+		func() (*model.BindResponse, error) {
+			return c.adaptBindResponse(response, bindID, adapt, func(index int, endpoint model.Endpoint) (string, error) {
+				service, err := c.ConfigStore.GetService(bindID, serviceName(index, bindID))
+				if err != nil {
+					return "", err
+				}
+				return service.Spec.ClusterIP, nil
+			})
+		})
+}
+
+func (c ConsumerInterceptor) adaptBindResponse(response model.BindResponse, bindID string,
+	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error),
+	clusterIPOfEndpoint func(index int, endpoint model.Endpoint) (string, error)) (*model.BindResponse, error) {
 	var endpointMapping []model.EndpointMapping
 
 	networkDataMatches := (c.NetworkProfile == response.NetworkData.NetworkProfileID)
 	if !networkDataMatches {
-		log.Infoa("Ignoring bind request for network id:", response.NetworkData.NetworkProfileID)
+		c.logger.Infof("Ignoring bind request for network id: %s\n", response.NetworkData.NetworkProfileID)
 		return &response, nil
 	}
 
@@ -80,15 +198,10 @@ func (c ConsumerInterceptor) PostBind(request model.BindRequest, response model.
 			len(response.NetworkData.Data.Endpoints), len(response.Endpoints))
 	}
 
-	endCleanupCondition := func(index int, err error) bool {
-		return index >= len(response.NetworkData.Data.Endpoints)
-	}
-
-	log.Debugf("Number of endpoints: %d\n", len(response.NetworkData.Data.Endpoints))
+	c.logger.Debugf("Number of endpoints: %d\n", len(response.NetworkData.Data.Endpoints))
 	for index, endpoint := range response.NetworkData.Data.Endpoints {
-		clusterIP, err := CreateIstioObjectsInK8S(c.ConfigStore, bindID, serviceName(index, bindID), endpoint, response.NetworkData.Data.ProviderID)
+		clusterIP, err := clusterIPOfEndpoint(index, endpoint)
 		if err != nil {
-			c.cleanUpConfig(bindID, endCleanupCondition)
 			return nil, err
 		}
 		endpointMapping = append(endpointMapping,
@@ -97,8 +210,8 @@ func (c ConsumerInterceptor) PostBind(request model.BindRequest, response model.
 				Target: model.Endpoint{Host: clusterIP, Port: servicePort}})
 	}
 	binding, err := adapt(response.Credentials, endpointMapping)
+	c.audit(AuditEvent{Event: auditAdaptCredentials, BindingID: bindID, ProviderID: response.NetworkData.Data.ProviderID}, err)
 	if err != nil {
-		c.cleanUpConfig(bindID, endCleanupCondition)
 		return nil, err
 	}
 	binding.NetworkData = response.NetworkData
@@ -107,21 +220,37 @@ func (c ConsumerInterceptor) PostBind(request model.BindRequest, response model.
 }
 
 //CreateIstioObjectsInK8S create a service and istio routing rules
-func CreateIstioObjectsInK8S(configStore ConfigStore, bindingID string, name string, endpoint model.Endpoint, systemDomain string) (string, error) {
+func CreateIstioObjectsInK8S(configStore ConfigStore, instanceID string, bindingID string, name string, endpoint model.Endpoint, systemDomain string) (string, error) {
+	clusterIP, _, err := createIstioObjects(requestLogger{}, configStore, instanceID, bindingID, name, endpoint, systemDomain)
+	return clusterIP, err
+}
+
+// createIstioObjects returns the cluster IP of the created service and the names of all created objects
+func createIstioObjects(logger requestLogger, configStore ConfigStore, instanceID string, bindingID string, name string, endpoint model.Endpoint, systemDomain string) (string, []string, error) {
 	service := &v1.Service{Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Port: servicePort, TargetPort: intstr.FromInt(servicePort)}}}}
 	service.Name = name
-	log.Infoa("Creating istio objects for ", name)
-	service, err := configStore.CreateService(bindingID, service)
+	logger.Infof("Creating istio objects for %s\n", name)
+	// a service reused from an earlier call still belongs to its configurations
+	_, getErr := configStore.GetService(bindingID, name)
+	reused := getErr == nil
+	service, err := configStore.CreateService(instanceID, bindingID, service)
 	if err != nil {
-		log.Errora("error creating service:", err.Error())
-		return "", err
+		logger.Errorf("error creating service: %s\n", err.Error())
+		return "", nil, err
 	}
 	configurations := config.CreateEntriesForExternalServiceClient(service.Name, endpoint.Host, service.Spec.ClusterIP, 9000, systemDomain)
-	err = configStore.CreateIstioConfig(bindingID, configurations)
+	err = configStore.ApplyIstioConfig(instanceID, bindingID, configurations)
 	if err != nil {
-		return "", err
+		// the configurations have been rolled back, the service would remain without them
+		if !reused {
+			deleteErr := configStore.DeleteService(bindingID, service.Name)
+			if deleteErr != nil {
+				logger.Warnf("Ignoring error during removal of service %s: %s\n", service.Name, deleteErr.Error())
+			}
+		}
+		return "", nil, err
 	}
-	return service.Spec.ClusterIP, nil
+	return service.Spec.ClusterIP, append([]string{"Service/" + service.Name}, istioObjectNames(configurations)...), nil
 }
 
 func serviceName(index int, bindID string) string {
@@ -131,17 +260,47 @@ func serviceName(index int, bindID string) string {
 
 //PostUnbind see interface definition
 func (c ConsumerInterceptor) PostUnbind(bindID string) {
-	c.cleanUpConfig(bindID, func(index int, err error) bool {
+	err := c.cleanUpConfig(bindID, func(index int, err error) bool {
 		return err != nil && index > 2
 	})
+	c.audit(AuditEvent{Event: auditUnbind, BindingID: bindID}, err)
+	err = c.ConfigStore.DeleteBindRequest(bindID)
+	if err != nil {
+		c.logger.Warnf("Ignoring error during removal of bind request %s: %s\n", bindID, err.Error())
+	}
 }
 
-func (c ConsumerInterceptor) cleanUpConfig(bindID string, endCleanupCondition func(index int, err error) bool) {
+//PostAsyncDeprovision see interface definition
+func (c ConsumerInterceptor) PostAsyncDeprovision(instanceID string) error {
+	return c.ConfigStore.StoreDeprovision(instanceID)
+}
+
+//PostLastOperationSucceeded see interface definition
+func (c ConsumerInterceptor) PostLastOperationSucceeded(instanceID string) bool {
+	return postLastOperationSucceeded(c.logger, c.ConfigStore, instanceID, c.PostDeprovision)
+}
+
+//PostDeprovision see interface definition
+func (c ConsumerInterceptor) PostDeprovision(instanceID string) {
+	err := c.ConfigStore.DeleteInstance(instanceID)
+	if err != nil {
+		c.logger.Warnf("Ignoring error during removal of configuration of instance %s: %s\n", instanceID, err.Error())
+	}
+}
+
+func (c ConsumerInterceptor) cleanUpConfig(bindID string, endCleanupCondition func(index int, err error) bool) error {
 
 	err := c.ConfigStore.DeleteBinding(bindID)
 	if err != nil {
-		log.Warnf("Ignoring error during removal of configuration %s: %s\n", bindID, err.Error())
+		c.logger.Warnf("Ignoring error during removal of configuration %s: %s\n", bindID, err.Error())
 	}
+	return err
+}
+
+func (c ConsumerInterceptor) audit(event AuditEvent, err error) {
+	event.ConsumerID = c.ConsumerID
+	event.NetworkProfile = c.NetworkProfile
+	recordAudit(c.ctx, c.AuditLog, event, err)
 }
 
 //HasAdaptCredentials see interface definition
@@ -77,7 +77,7 @@ func TestConsumerPostBind(t *testing.T) {
 	kubernetes := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &kubernetes}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "678", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(kubernetes.CreatedServices).To(HaveLen(1))
 	g.Expect(kubernetes.CreatedServices[0].Name).To(Equal("svc-0-678"))
@@ -89,7 +89,7 @@ func TestConsumerPostBindReturnsError(t *testing.T) {
 	kubernetes := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &kubernetes}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "678", adaptError)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "678", adaptError)
 	g.Expect(err).To(HaveOccurred())
 }
 
@@ -98,7 +98,7 @@ func TestNoEndpointsPresent(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, model.BindResponse{}, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, model.BindResponse{}, "instance-id", "678", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(configStore.CreatedServices).To(BeNil())
 	g.Expect(configStore.CreatedIstioConfigs).To(BeNil())
@@ -124,7 +124,7 @@ func TestEndpointsMappingWorks(t *testing.T) {
 		}.ToCredentials(),
 			Endpoints:   endpoints,
 			NetworkData: model.NetworkDataResponse{NetworkProfileID: "testprofile", Data: model.DataResponse{Endpoints: endpoints}}},
-		"678", model.Adapt)
+		"instance-id", "678", model.Adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	g.Expect(binding.Endpoints).NotTo(BeNil())
@@ -148,7 +148,7 @@ func TestBindIdIsPartOfServiceName(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "555", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "555", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(configStore.CreatedServices).To(HaveLen(1))
 	g.Expect(configStore.CreatedServices[0].Name).To(Equal("svc-0-555"))
@@ -159,7 +159,7 @@ func TestMaximumLengthIsNotExceededWithRealBindId(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "f1b32107-c8a5-11e8-b8be-02caceffa7f1", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "f1b32107-c8a5-11e8-b8be-02caceffa7f1", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	const maxLabelLength = 63
@@ -173,7 +173,7 @@ func TestEndpointIndexIsPartOfServiceName(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "adf123", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "adf123", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(configStore.CreatedServices).To(HaveLen(2))
 	g.Expect(configStore.CreatedServices[1].Name).To(Equal("svc-1-adf123"))
@@ -184,7 +184,7 @@ func TestConsumerInterceptorCreatesIstioObjects(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "678", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	g.Expect(len(configStore.CreatedIstioConfigs)).To(Equal(6))
@@ -205,7 +205,7 @@ func TestTwoEndpointsCreateTwelveObject(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	g.Expect(len(configStore.CreatedIstioConfigs)).To(Equal(12))
@@ -226,7 +226,7 @@ func TestTwoEndpointsHasTheCorrcetCount(t *testing.T) {
 					Port: 9001}}}}}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponse, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponse, "instance-id", "678", adapt)
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(ContainSubstring("Number of endpoints"))
 }
@@ -236,7 +236,7 @@ func TestClusterIpIsUsed(t *testing.T) {
 	configStore := MockConfigStore{ClusterIP: "9.8.7.6"}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	g.Expect(configStore.CreatedIstioConfigs).To(HaveLen(12))
@@ -249,7 +249,7 @@ func TestCreateServiceErrorIsHandled(t *testing.T) {
 	configStore := MockConfigStore{CreateServiceErr: fmt.Errorf("Test service error")}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(Equal("Test service error"))
 }
@@ -259,7 +259,7 @@ func TestCreateObjectErrorIsHandled(t *testing.T) {
 	configStore := MockConfigStore{CreateObjectErr: fmt.Errorf("Test object error")}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(Equal("Test object error"))
 }
@@ -269,7 +269,7 @@ func TestConsumerPostUnbind(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	consumer.PostUnbind("678")
@@ -294,7 +294,7 @@ func TestConsumerPostUnbindNoResourceLeaks(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 
 	g.Expect(err).NotTo(HaveOccurred())
 
@@ -310,13 +310,75 @@ func TestConsumerPostUnbindNoResourceLeaks(t *testing.T) {
 	g.Expect(configStore.CreatedIstioConfigs).To(HaveLen(0))
 }
 
+func TestConsumerRepeatedPostBindIsIdempotent(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{ClusterIP: "1.2.3.4"}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configStore.ClusterIP = "5.6.7.8"
+	var mappings []model.EndpointMapping
+	_, err = consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678",
+		func(credentials model.Credentials, endpointMappings []model.EndpointMapping) (*model.BindResponse, error) {
+			mappings = endpointMappings
+			return &model.BindResponse{Credentials: credentials}, nil
+		})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configStore.CreatedServices).To(HaveLen(2))
+	g.Expect(configStore.CreatedIstioConfigs).To(HaveLen(12))
+	g.Expect(configStore.DeletedServices).To(BeEmpty())
+	g.Expect(mappings[0].Target.Host).To(Equal("1.2.3.4"))
+}
+
+func TestConsumerConflictingPostBindKeepsExistingBinding(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "678", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	conflictingResponse := bindResponseSingleEndpoint
+	conflictingResponse.NetworkData.Data.Endpoints = []model.Endpoint{{Host: "0.678.other.domain.io", Port: 9001}}
+	_, err = consumer.PostBind(model.BindRequest{}, conflictingResponse, "instance-id", "678", adapt)
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.StatusCode).To(Equal(http.StatusConflict))
+	g.Expect(configStore.CreatedServices).To(HaveLen(1))
+	g.Expect(configStore.CreatedIstioConfigs).To(HaveLen(6))
+	g.Expect(configStore.DeletedIstioConfigs).To(BeEmpty())
+}
+
+func TestConsumerPostDeprovision(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "679", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "other-instance-id", "680", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	consumer.PostDeprovision("instance-id")
+	g.Expect(configStore.DeletedServices).To(ConsistOf("svc-0-678", "svc-1-678", "svc-0-679"))
+	g.Expect(configStore.DeletedIstioConfigs).To(HaveLen(18))
+	g.Expect(configStore.CreatedServices).To(HaveLen(1))
+	g.Expect(configStore.CreatedIstioConfigs).To(HaveLen(6))
+}
+
 func TestConsumerFailingPostBindGetsCleanedUp(t *testing.T) {
 	g := NewGomegaWithT(t)
 	configStore := MockConfigStore{CreateObjectErrCount: 3, CreateObjectErr: errors.New("No more objects")}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
 
-	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "678", adapt)
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
 	g.Expect(err).To(HaveOccurred())
 
 	g.Expect(configStore.CreatedServices).To(HaveLen(0))
@@ -346,7 +408,7 @@ func TestBindAdaptEndpointsOnlyIfNetworkProfilesMatch(t *testing.T) {
 	configStore := MockConfigStore{}
 
 	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore, NetworkProfile: "urn:my.test:public"}
-	binding, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "555", adaptError)
+	binding, err := consumer.PostBind(model.BindRequest{}, bindResponseSingleEndpoint, "instance-id", "555", adaptError)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(*binding).To(Equal(bindResponseSingleEndpoint))
 }
@@ -413,3 +475,182 @@ func TestPostProvisionWithInvalidNetworkProfile(t *testing.T) {
 	g.Expect(httpError.ErrorMsg).To(Equal("InvalidProducerNetworkProfile"))
 	g.Expect(httpError.Description).To(ContainSubstring("urn:x.y:public"))
 }
+
+func TestPreUpdateRequestInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	request := model.UpdateRequest{NetworkProfiles: []model.NetworkProfile{{ID: "test:x.y:public"}}}
+	_, err := consumer.PreUpdate(request)
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.StatusCode).To(Equal(http.StatusBadRequest))
+	g.Expect(httpError.ErrorMsg).To(Equal("InvalidNetworkProfile"))
+}
+
+func TestPreUpdateAddsNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	updateRequest, err := consumer.PreUpdate(model.UpdateRequest{})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(updateRequest.NetworkProfiles).To(ConsistOf(model.NetworkProfile{ID: "urn:my.test:public"}))
+}
+
+func TestPostUpdateRemovesNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	response := model.UpdateResponse{NetworkProfiles: []model.NetworkProfile{{ID: "urn:my.test:public"}}}
+	updateResponse, err := consumer.PostUpdate(model.UpdateRequest{}, response)
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(updateResponse.NetworkProfiles).To(BeEmpty())
+}
+
+func TestPostUpdateWithInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	response := model.UpdateResponse{NetworkProfiles: []model.NetworkProfile{{ID: "urn:x.y:public"}}}
+	_, err := consumer.PostUpdate(model.UpdateRequest{}, response)
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.StatusCode).To(Equal(http.StatusInternalServerError))
+	g.Expect(httpError.ErrorMsg).To(Equal("InvalidProducerNetworkProfile"))
+	g.Expect(httpError.Description).To(ContainSubstring("urn:x.y:public"))
+}
+
+func TestPostLastOperationRemovesNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	response := model.LastOperationResponse{State: model.OperationSucceeded, NetworkProfiles: []model.NetworkProfile{{ID: "urn:my.test:public"}}}
+	lastOperation, err := consumer.PostLastOperation(response)
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lastOperation.NetworkProfiles).To(BeEmpty())
+	g.Expect(lastOperation.State).To(Equal(model.OperationSucceeded))
+}
+
+func TestPostLastOperationWithInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	response := model.LastOperationResponse{State: model.OperationSucceeded, NetworkProfiles: []model.NetworkProfile{{ID: "urn:x.y:public"}}}
+	_, err := consumer.PostLastOperation(response)
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.ErrorMsg).To(Equal("InvalidProducerNetworkProfile"))
+}
+
+func TestPostLastOperationInProgressIsNotValidated(t *testing.T) {
+	g := NewGomegaWithT(t)
+	consumer := ConsumerInterceptor{
+		ConsumerID:     "consumer-id",
+		NetworkProfile: "urn:my.test:public",
+	}
+
+	lastOperation, err := consumer.PostLastOperation(model.LastOperationResponse{State: model.OperationInProgress})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lastOperation.State).To(Equal(model.OperationInProgress))
+}
+
+func TestConsumerAsyncBindCreatesIstioObjectsOnFetch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	err := consumer.PostAsyncBind(model.BindRequest{}, "instance-id", "678")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configStore.CreatedServices).To(BeEmpty())
+
+	_, err = consumer.PostFetchBinding(bindResponseSingleEndpoint, "instance-id", "678", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configStore.CreatedServices).To(HaveLen(1))
+	g.Expect(configStore.CreatedServices[0].Name).To(Equal("svc-0-678"))
+	g.Expect(configStore.BindRequests).NotTo(HaveKey("678"))
+}
+
+func TestConsumerFetchBindingUsesExistingServices(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{ClusterIP: "9.8.7.6"}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	_, err := consumer.PostBind(model.BindRequest{}, bindResponseTwoEndpoints, "instance-id", "678", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	configStore.ClusterIP = "1.1.1.1"
+	var mappings []model.EndpointMapping
+	binding, err := consumer.PostFetchBinding(bindResponseTwoEndpoints, "instance-id", "678",
+		func(credentials model.Credentials, endpointMappings []model.EndpointMapping) (*model.BindResponse, error) {
+			mappings = endpointMappings
+			return &model.BindResponse{Credentials: credentials}, nil
+		})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configStore.CreatedServices).To(HaveLen(2))
+	g.Expect(configStore.CreatedIstioConfigs).To(HaveLen(12))
+	g.Expect(mappings).To(HaveLen(2))
+	g.Expect(mappings[0].Target).To(Equal(model.Endpoint{Host: "9.8.7.6", Port: 5555}))
+	g.Expect(mappings[1].Source).To(Equal(bindResponseTwoEndpoints.Endpoints[1]))
+	g.Expect(binding.NetworkData).To(Equal(bindResponseTwoEndpoints.NetworkData))
+}
+
+func TestConsumerFetchBindingWithoutServicesFails(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	_, err := consumer.PostFetchBinding(bindResponseSingleEndpoint, "instance-id", "678", adapt)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(configStore.CreatedServices).To(BeEmpty())
+}
+
+func TestConsumerFetchBindingIgnoresOtherNetworkProfiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore, NetworkProfile: "urn:my.test:public"}
+	binding, err := consumer.PostFetchBinding(bindResponseSingleEndpoint, "instance-id", "678", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(binding.Endpoints).To(Equal(bindResponseSingleEndpoint.Endpoints))
+}
+
+func TestConsumerFailingAsyncBindKeepsBindRequest(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := MockConfigStore{CreateServiceErr: errors.New("Test service error")}
+
+	consumer := ConsumerInterceptor{ConsumerID: "consumer-id", ConfigStore: &configStore}
+	err := consumer.PostAsyncBind(model.BindRequest{}, "instance-id", "678")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = consumer.PostFetchBinding(bindResponseSingleEndpoint, "instance-id", "678", adapt)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(configStore.BindRequests).To(HaveKey("678"))
+
+	consumer.PostUnbind("678")
+	g.Expect(configStore.BindRequests).NotTo(HaveKey("678"))
+}
@@ -1,15 +1,24 @@
 package router
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Peripli/istio-broker-proxy/pkg/config"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
 	"io/ioutil"
-	"istio.io/istio/pilot/pkg/model"
+	istioModel "istio.io/istio/pilot/pkg/model"
 	"k8s.io/api/core/v1"
 	"istio.io/istio/pkg/log"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	instanceIDSuffix  = ".instance-id"
+	deprovisionSuffix = ".deprovision"
 )
 
 type fileConfigStore struct {
@@ -23,7 +32,7 @@ func NewFileConfigStore(dir string) ConfigStore {
 	return &fileConfigStore{istioDirectory: dir}
 }
 
-func (f *fileConfigStore) CreateIstioConfig(bindingID string, configuration []model.Config) error {
+func (f *fileConfigStore) ApplyIstioConfig(instanceID string, bindingID string, configuration []istioModel.Config) error {
 	ymlPath := path.Join(f.istioDirectory, bindingID) + ".yml"
 	log.Debugf("PATH to istio config: %v\n", ymlPath)
 
@@ -31,22 +40,198 @@ func (f *fileConfigStore) CreateIstioConfig(bindingID string, configuration []mo
 	if nil != err {
 		return err
 	}
-	err = ioutil.WriteFile(ymlPath, []byte(fileContent), 0644)
+	_, err = os.Stat(ymlPath)
+	existed := err == nil
+	// the configuration is written to a temporary file and renamed, so that it is never applied partially
+	err = writeFileAtomically(ymlPath, []byte(fileContent))
 	if nil != err {
 		return fmt.Errorf("unable to write istio configuration to file %s: %v", ymlPath, err)
 	}
-	return nil
+	err = f.writeInstanceID(instanceID, bindingID)
+	if err != nil && !existed {
+		os.Remove(ymlPath)
+	}
+	return err
+}
+
+func writeFileAtomically(fileName string, content []byte) error {
+	file, err := ioutil.TempFile(path.Dir(fileName), "."+path.Base(fileName)+".")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fileName)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
 }
 
 func (f *fileConfigStore) DeleteBinding(bindingID string) error {
+	err := os.Remove(f.instanceIDFile(bindingID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	fileName := path.Join(f.istioDirectory, bindingID) + ".yml"
-	err := os.Remove(fileName)
+	err = os.Remove(fileName)
 	if err != nil {
 		return fmt.Errorf("Error during removal of file %s: %v", fileName, err)
 	}
 	return nil
 }
 
-func (f *fileConfigStore) CreateService(bindingID string, service *v1.Service) (*v1.Service, error) {
+func (f *fileConfigStore) DeleteInstance(instanceID string) error {
+	fileNames, err := filepath.Glob(path.Join(f.istioDirectory, "*"+instanceIDSuffix))
+	if err != nil {
+		return err
+	}
+	for _, fileName := range fileNames {
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		if string(content) != instanceID {
+			continue
+		}
+		bindingID := strings.TrimSuffix(path.Base(fileName), instanceIDSuffix)
+		err = f.DeleteBindRequest(bindingID)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(path.Join(f.istioDirectory, bindingID) + ".yml")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		err = os.Remove(fileName)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Remove(f.deprovisionFile(instanceID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileConfigStore) StoreDeprovision(instanceID string) error {
+	fileName := f.deprovisionFile(instanceID)
+	err := ioutil.WriteFile(fileName, []byte(instanceID), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write deprovisioning to file %s: %v", fileName, err)
+	}
+	return nil
+}
+
+func (f *fileConfigStore) IsDeprovisioning(instanceID string) (bool, error) {
+	_, err := os.Stat(f.deprovisionFile(instanceID))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *fileConfigStore) deprovisionFile(instanceID string) string {
+	return path.Join(f.istioDirectory, instanceID) + deprovisionSuffix
+}
+
+func (f *fileConfigStore) writeInstanceID(instanceID string, bindingID string) error {
+	if instanceID == "" {
+		return nil
+	}
+	fileName := f.instanceIDFile(bindingID)
+	err := ioutil.WriteFile(fileName, []byte(instanceID), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write instance id to file %s: %v", fileName, err)
+	}
+	return nil
+}
+
+func (f *fileConfigStore) CountBindings() (int, error) {
+	fileNames, err := filepath.Glob(path.Join(f.istioDirectory, "*.yml"))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, fileName := range fileNames {
+		if isBinding(strings.TrimSuffix(path.Base(fileName), ".yml")) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fileConfigStore) Health() error {
+	file, err := ioutil.TempFile(f.istioDirectory, ".health-")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+func (f *fileConfigStore) instanceIDFile(bindingID string) string {
+	return path.Join(f.istioDirectory, bindingID) + instanceIDSuffix
+}
+
+func (f *fileConfigStore) CreateService(instanceID string, bindingID string, service *v1.Service) (*v1.Service, error) {
 	return nil, errors.New("CreateService is not available for file system")
 }
+
+func (f *fileConfigStore) GetService(bindingID string, name string) (*v1.Service, error) {
+	return nil, errors.New("GetService is not available for file system")
+}
+
+func (f *fileConfigStore) DeleteService(bindingID string, name string) error {
+	return errors.New("DeleteService is not available for file system")
+}
+
+func (f *fileConfigStore) StoreBindRequest(instanceID string, bindingID string, request model.BindRequest) error {
+	fileContent, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	fileName := f.bindRequestFile(bindingID)
+	err = ioutil.WriteFile(fileName, fileContent, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write bind request to file %s: %v", fileName, err)
+	}
+	return f.writeInstanceID(instanceID, bindingID)
+}
+
+func (f *fileConfigStore) LoadBindRequest(bindingID string) (*model.BindRequest, error) {
+	fileContent, err := ioutil.ReadFile(f.bindRequestFile(bindingID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var request model.BindRequest
+	err = json.Unmarshal(fileContent, &request)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (f *fileConfigStore) DeleteBindRequest(bindingID string) error {
+	err := os.Remove(f.bindRequestFile(bindingID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileConfigStore) bindRequestFile(bindingID string) string {
+	return path.Join(f.istioDirectory, bindingID) + ".bind-request.json"
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	osbModel "github.com/Peripli/istio-broker-proxy/pkg/model"
 	. "github.com/onsi/gomega"
 	"io/ioutil"
 	"istio.io/istio/pilot/pkg/model"
@@ -18,7 +19,7 @@ func TestFileConfigStore(t *testing.T) {
 	g := NewGomegaWithT(t)
 
 	fileCS := newTmpFileConfigStore()
-	err := fileCS.CreateIstioConfig("binding-id", []model.Config{})
+	err := fileCS.ApplyIstioConfig("instance-id", "binding-id", []model.Config{})
 
 	g.Expect(err).NotTo(HaveOccurred())
 }
@@ -27,7 +28,7 @@ func TestFileConfigStoreInvalidDirectory(t *testing.T) {
 	g := NewGomegaWithT(t)
 
 	fileCS := &fileConfigStore{istioDirectory: "/invalid-directory"}
-	err := fileCS.CreateIstioConfig("binding-id", []model.Config{})
+	err := fileCS.ApplyIstioConfig("instance-id", "binding-id", []model.Config{})
 
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(ContainSubstring("unable to write istio configuration to file"))
@@ -59,7 +60,83 @@ func TestFileConfigStoreCreateService(t *testing.T) {
 	g := NewGomegaWithT(t)
 
 	fileCS := newTmpFileConfigStore()
-	_, err := fileCS.CreateService("binding-id", &v1.Service{})
+	_, err := fileCS.CreateService("instance-id", "binding-id", &v1.Service{})
 
 	g.Expect(err).To(HaveOccurred())
 }
+
+func TestFileConfigStoreBindRequest(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fileCS := newTmpFileConfigStore()
+	request := osbModel.BindRequest{NetworkData: osbModel.NetworkDataRequest{Data: osbModel.DataRequest{ConsumerID: "consumer-id"}}}
+	err := fileCS.StoreBindRequest("instance-id", "binding-id", request)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	stored, err := fileCS.LoadBindRequest("binding-id")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored.NetworkData.Data.ConsumerID).To(Equal("consumer-id"))
+
+	err = fileCS.DeleteBindRequest("binding-id")
+	g.Expect(err).NotTo(HaveOccurred())
+	stored, err = fileCS.LoadBindRequest("binding-id")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stored).To(BeNil())
+}
+
+func TestFileConfigStoreStoreBindRequestInvalidDirectory(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fileCS := &fileConfigStore{istioDirectory: "/invalid-directory"}
+	err := fileCS.StoreBindRequest("instance-id", "binding-id", osbModel.BindRequest{})
+
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unable to write bind request to file"))
+}
+
+func TestFileConfigStoreDeleteInstance(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "file-config-store")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	fileCS := NewFileConfigStore(dir)
+
+	g.Expect(fileCS.ApplyIstioConfig("instance-id", "binding-id", []model.Config{})).To(Succeed())
+	g.Expect(fileCS.StoreBindRequest("instance-id", "async-binding-id", osbModel.BindRequest{})).To(Succeed())
+	g.Expect(fileCS.ApplyIstioConfig("other-instance-id", "other-binding-id", []model.Config{})).To(Succeed())
+
+	err = fileCS.DeleteInstance("instance-id")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	files, err := ioutil.ReadDir(dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	var fileNames []string
+	for _, file := range files {
+		fileNames = append(fileNames, file.Name())
+	}
+	g.Expect(fileNames).To(ConsistOf("other-binding-id.yml", "other-binding-id.instance-id"))
+}
+
+func TestFileConfigStoreHealth(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(newTmpFileConfigStore().Health()).To(Succeed())
+	g.Expect(NewFileConfigStore("/invalid-directory").Health()).NotTo(Succeed())
+}
+
+func TestFileConfigStoreApplyLeavesNoTemporaryFiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "istio-config")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	g.Expect(NewFileConfigStore(dir).ApplyIstioConfig("instance-id", "binding-id", []model.Config{})).To(Succeed())
+
+	files, err := ioutil.ReadDir(dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	g.Expect(names).To(ConsistOf("binding-id.yml", "binding-id"+instanceIDSuffix))
+}
@@ -0,0 +1,123 @@
+package router
+
+import (
+	"context"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	istioModel "istio.io/istio/pilot/pkg/model"
+	"k8s.io/api/core/v1"
+	"time"
+)
+
+type instrumentedConfigStore struct {
+	delegate ConfigStore
+}
+
+//NewInstrumentedConfigStore creates a ConfigStore which exposes latency and errors of each call as well as the number
+//of active bindings of the given ConfigStore via /metrics
+func NewInstrumentedConfigStore(configStore ConfigStore) ConfigStore {
+	instrumented := &instrumentedConfigStore{configStore}
+	registerActiveBindings(instrumented)
+	return instrumented
+}
+
+func (s *instrumentedConfigStore) withContext(ctx context.Context) ConfigStore {
+	return &instrumentedConfigStore{configStoreWithContext(ctx, s.delegate)}
+}
+
+func observeConfigStore(method string, start time.Time, err error) {
+	configStoreDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		configStoreErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (s *instrumentedConfigStore) CreateService(instanceID string, bindingID string, service *v1.Service) (*v1.Service, error) {
+	start := time.Now()
+	result, err := s.delegate.CreateService(instanceID, bindingID, service)
+	observeConfigStore("CreateService", start, err)
+	return result, err
+}
+
+func (s *instrumentedConfigStore) GetService(bindingID string, name string) (*v1.Service, error) {
+	start := time.Now()
+	result, err := s.delegate.GetService(bindingID, name)
+	observeConfigStore("GetService", start, err)
+	return result, err
+}
+
+func (s *instrumentedConfigStore) DeleteService(bindingID string, name string) error {
+	start := time.Now()
+	err := s.delegate.DeleteService(bindingID, name)
+	observeConfigStore("DeleteService", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) ApplyIstioConfig(instanceID string, bindingID string, config []istioModel.Config) error {
+	start := time.Now()
+	err := s.delegate.ApplyIstioConfig(instanceID, bindingID, config)
+	observeConfigStore("ApplyIstioConfig", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) DeleteBinding(bindingID string) error {
+	start := time.Now()
+	err := s.delegate.DeleteBinding(bindingID)
+	observeConfigStore("DeleteBinding", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) DeleteInstance(instanceID string) error {
+	start := time.Now()
+	err := s.delegate.DeleteInstance(instanceID)
+	observeConfigStore("DeleteInstance", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) StoreBindRequest(instanceID string, bindingID string, request model.BindRequest) error {
+	start := time.Now()
+	err := s.delegate.StoreBindRequest(instanceID, bindingID, request)
+	observeConfigStore("StoreBindRequest", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) LoadBindRequest(bindingID string) (*model.BindRequest, error) {
+	start := time.Now()
+	result, err := s.delegate.LoadBindRequest(bindingID)
+	observeConfigStore("LoadBindRequest", start, err)
+	return result, err
+}
+
+func (s *instrumentedConfigStore) DeleteBindRequest(bindingID string) error {
+	start := time.Now()
+	err := s.delegate.DeleteBindRequest(bindingID)
+	observeConfigStore("DeleteBindRequest", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) Health() error {
+	start := time.Now()
+	err := s.delegate.Health()
+	observeConfigStore("Health", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) StoreDeprovision(instanceID string) error {
+	start := time.Now()
+	err := s.delegate.StoreDeprovision(instanceID)
+	observeConfigStore("StoreDeprovision", start, err)
+	return err
+}
+
+func (s *instrumentedConfigStore) IsDeprovisioning(instanceID string) (bool, error) {
+	start := time.Now()
+	result, err := s.delegate.IsDeprovisioning(instanceID)
+	observeConfigStore("IsDeprovisioning", start, err)
+	return result, err
+}
+
+func (s *instrumentedConfigStore) CountBindings() (int, error) {
+	start := time.Now()
+	count, err := s.delegate.CountBindings()
+	observeConfigStore("CountBindings", start, err)
+	return count, err
+}
@@ -1,59 +1,227 @@
 package router
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/api"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/Peripli/istio-broker-proxy/pkg/tracing"
+	"net/http"
+	"net/url"
 )
 
 type interceptedOsbClient struct {
 	OsbClient   *osbClient
 	Interceptor ServiceBrokerInterceptor
+	ctx         context.Context
+	// orphanMitigated is set once the binding has been removed from the broker successfully
+	orphanMitigated bool
 }
 
-func (c *interceptedOsbClient) GetCatalog() (*model.Catalog, error) {
-	catalog, err := c.OsbClient.getCatalog()
+// intercept runs a step of the interceptor within its own span
+func (c *interceptedOsbClient) intercept(step string, f func(interceptor ServiceBrokerInterceptor) error) error {
+	ctx, span := tracing.StartSpan(c.ctx, step)
+	interceptor := c.Interceptor
+	if contextAware, ok := interceptor.(ContextAware); ok {
+		interceptor = contextAware.WithContext(ctx)
+	}
+	err := f(interceptor)
+	span.Finish(err)
+	return err
+}
+
+func (c *interceptedOsbClient) GetCatalog() (*model.Catalog, api.RESTResponse, error) {
+	catalog, response, err := c.OsbClient.getCatalog()
 	if err != nil {
-		return nil, err
+		return nil, response, err
 	}
-	err = c.Interceptor.PostCatalog(catalog)
-	return catalog, err
+	err = c.intercept("PostCatalog", func(interceptor ServiceBrokerInterceptor) error {
+		return interceptor.PostCatalog(catalog)
+	})
+	countRejection("catalog", err)
+	return catalog, response, err
 }
 
-func (c *interceptedOsbClient) Bind(bindingID string, bindRequest *model.BindRequest) (*model.BindResponse, error) {
-	bindRequest, err := c.Interceptor.PreBind(*bindRequest)
+func (c *interceptedOsbClient) Bind(instanceID string, bindingID string, bindRequest *model.BindRequest) (*model.BindResponse, api.RESTResponse, error) {
+	err := c.intercept("PreBind", func(interceptor ServiceBrokerInterceptor) (err error) {
+		bindRequest, err = interceptor.PreBind(*bindRequest)
+		return err
+	})
+	countRejection("bind", err)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	bindResponse, err := c.OsbClient.bind(bindRequest)
+	bindResponse, response, err := c.OsbClient.bind(bindRequest)
 	if err != nil {
-		return nil, err
+		return nil, response, err
 	}
 
-	return c.Interceptor.PostBind(*bindRequest, *bindResponse, bindingID,
-		func(credentials model.Credentials, mappings []model.EndpointMapping) (*model.BindResponse, error) {
-			return c.OsbClient.adaptCredentials(credentials, mappings)
+	if response.StatusCode() == http.StatusAccepted {
+		err = c.intercept("PostAsyncBind", func(interceptor ServiceBrokerInterceptor) error {
+			return interceptor.PostAsyncBind(*bindRequest, instanceID, bindingID)
 		})
+		countRejection("bind", err)
+		if err != nil {
+			return nil, response, err
+		}
+		return bindResponse, response, nil
+	}
+
+	err = c.intercept("PostBind", func(interceptor ServiceBrokerInterceptor) (err error) {
+		bindResponse, err = interceptor.PostBind(*bindRequest, *bindResponse, instanceID, bindingID, c.adaptCredentials)
+		return err
+	})
+	countRejection("bind", err)
+	if err != nil && !isConflict(err) {
+		return nil, response, c.mitigateOrphan(*bindRequest, err)
+	}
+	return bindResponse, response, err
+}
+
+// mitigateOrphan removes a binding at the broker which can't be used because PostBind failed
+func (c *interceptedOsbClient) mitigateOrphan(bindRequest model.BindRequest, bindErr error) error {
+	httpError := *model.HTTPErrorFromError(bindErr, http.StatusInternalServerError)
+	query := url.Values{}
+	for _, key := range []string{"service_id", "plan_id"} {
+		var value string
+		json.Unmarshal(bindRequest.AdditionalProperties[key], &value)
+		query.Set(key, value)
+	}
+	_, err := c.OsbClient.unbindWithQuery(query)
+	if err != nil {
+		requestLog(c.ctx).Errorf("Orphan mitigation failed: %s\n", err.Error())
+		httpError.Description = fmt.Sprintf("%s; orphan mitigation failed: %s", httpError.Description, err.Error())
+	} else {
+		c.orphanMitigated = true
+		httpError.Description = fmt.Sprintf("%s; orphan mitigation succeeded: binding removed from broker", httpError.Description)
+	}
+	return httpError
+}
+
+func (c *interceptedOsbClient) FetchBinding(instanceID string, bindingID string) (*model.BindResponse, api.RESTResponse, error) {
+	bindResponse, response, err := c.OsbClient.fetchBinding()
+	if err != nil {
+		return nil, response, err
+	}
+	err = c.intercept("PostFetchBinding", func(interceptor ServiceBrokerInterceptor) (err error) {
+		bindResponse, err = interceptor.PostFetchBinding(*bindResponse, instanceID, bindingID, c.adaptCredentials)
+		return err
+	})
+	return bindResponse, response, countRejection("fetch_binding", err)
+}
+
+func (c *interceptedOsbClient) adaptCredentials(credentials model.Credentials, mappings []model.EndpointMapping) (*model.BindResponse, error) {
+	return c.OsbClient.adaptCredentials(credentials, mappings)
+}
+
+func (c *interceptedOsbClient) Unbind(bindID string) (api.RESTResponse, error) {
+	response, err := c.OsbClient.unbind()
+	c.intercept("PostUnbind", func(interceptor ServiceBrokerInterceptor) error {
+		interceptor.PostUnbind(bindID)
+		return nil
+	})
+	if err != nil {
+		return response, err
+	}
+	return response, nil
 }
 
-func (c *interceptedOsbClient) Unbind(bindID string) error {
-	err := c.OsbClient.unbind()
-	c.Interceptor.PostUnbind(bindID)
+func (c *interceptedOsbClient) Deprovision(instanceID string) (map[string]interface{}, api.RESTResponse, error) {
+	deprovisionResponse, response, err := c.OsbClient.deprovision()
+	// 410 Gone means the instance does not exist (anymore), so its configuration is obsolete as well
+	if response.StatusCode() == http.StatusOK || response.StatusCode() == http.StatusGone {
+		c.postDeprovision(instanceID)
+	}
 	if err != nil {
+		return nil, response, err
+	}
+	if response.StatusCode() == http.StatusAccepted {
+		err = c.intercept("PostAsyncDeprovision", func(interceptor ServiceBrokerInterceptor) error {
+			return interceptor.PostAsyncDeprovision(instanceID)
+		})
+		if err != nil {
+			return nil, response, err
+		}
+	}
+	return deprovisionResponse, response, nil
+}
+
+func (c *interceptedOsbClient) postDeprovision(instanceID string) {
+	c.intercept("PostDeprovision", func(interceptor ServiceBrokerInterceptor) error {
+		interceptor.PostDeprovision(instanceID)
+		return nil
+	})
+}
+
+func (c *interceptedOsbClient) Provision(provisionRequest *model.ProvisionRequest) (*model.ProvisionResponse, api.RESTResponse, error) {
+	err := c.intercept("PreProvision", func(interceptor ServiceBrokerInterceptor) (err error) {
+		provisionRequest, err = interceptor.PreProvision(*provisionRequest)
 		return err
+	})
+	countRejection("provision", err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provisionResponse, response, err := c.OsbClient.provision(provisionRequest)
+	if err != nil {
+		return nil, response, err
 	}
-	return nil
+
+	err = c.intercept("PostProvision", func(interceptor ServiceBrokerInterceptor) (err error) {
+		provisionResponse, err = interceptor.PostProvision(*provisionRequest, *provisionResponse)
+		return err
+	})
+	return provisionResponse, response, countRejection("provision", err)
 }
 
-func (c *interceptedOsbClient) Provision(provisionRequest *model.ProvisionRequest) (*model.ProvisionResponse, error) {
-	provisionRequest, err := c.Interceptor.PreProvision(*provisionRequest)
+func (c *interceptedOsbClient) Update(updateRequest *model.UpdateRequest) (*model.UpdateResponse, api.RESTResponse, error) {
+	err := c.intercept("PreUpdate", func(interceptor ServiceBrokerInterceptor) (err error) {
+		updateRequest, err = interceptor.PreUpdate(*updateRequest)
+		return err
+	})
+	countRejection("update", err)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	provisionResponse, err := c.OsbClient.provision(provisionRequest)
+	updateResponse, response, err := c.OsbClient.update(updateRequest)
 	if err != nil {
-		return nil, err
+		return nil, response, err
 	}
 
-	return c.Interceptor.PostProvision(*provisionRequest, *provisionResponse)
+	err = c.intercept("PostUpdate", func(interceptor ServiceBrokerInterceptor) (err error) {
+		updateResponse, err = interceptor.PostUpdate(*updateRequest, *updateResponse)
+		return err
+	})
+	return updateResponse, response, countRejection("update", err)
+}
+
+func (c *interceptedOsbClient) GetLastOperation(instanceID string) (*model.LastOperationResponse, api.RESTResponse, error) {
+	lastOperation, response, err := c.OsbClient.getLastOperation()
+	// an asynchronous deprovisioning has finished
+	if response.StatusCode() == http.StatusGone {
+		c.postDeprovision(instanceID)
+	}
+	if err != nil {
+		return nil, response, err
+	}
+	if lastOperation.State == model.OperationSucceeded {
+		deprovisioned := false
+		c.intercept("PostLastOperationSucceeded", func(interceptor ServiceBrokerInterceptor) error {
+			deprovisioned = interceptor.PostLastOperationSucceeded(instanceID) || deprovisioned
+			return nil
+		})
+		// a finished deprovisioning has no network profiles to check
+		if deprovisioned {
+			return lastOperation, response, nil
+		}
+	}
+	err = c.intercept("PostLastOperation", func(interceptor ServiceBrokerInterceptor) (err error) {
+		lastOperation, err = interceptor.PostLastOperation(*lastOperation)
+		return err
+	})
+	return lastOperation, response, countRejection("last_operation", err)
 }
@@ -19,6 +19,6 @@ func TestInterceptedOsbClient(t *testing.T) {
 	g := NewGomegaWithT(t)
 
 	interceptedOsbClient := interceptedOsbClient{Interceptor: TestInterceptor{}}
-	_, err := interceptedOsbClient.Bind("test", &model.BindRequest{})
+	_, _, err := interceptedOsbClient.Bind("instance-id", "test", &model.BindRequest{})
 	g.Expect(err).To(HaveOccurred())
 }
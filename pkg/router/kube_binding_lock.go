@@ -0,0 +1,191 @@
+package router
+
+import (
+	"context"
+	coordination "k8s.io/api/coordination/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	bindingLockPrefix = "binding-lock-"
+	// a crashed replica can't release its locks, so they expire after this duration unless they are renewed
+	bindingLockDuration = 60 * time.Second
+)
+
+type kubeBindingLock struct {
+	clientset kubernetes.Interface
+	namespace string
+	holder    string
+	duration  time.Duration
+	mutex     sync.Mutex
+	renewals  map[string]chan struct{}
+}
+
+//NewInClusterBindingLock creates a BindingLock based on kubernetes leases which is effective across all replicas
+func NewInClusterBindingLock() BindingLock {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		panic(err.Error())
+	}
+	namespace, err := getNamespace()
+	if err != nil {
+		panic(err.Error())
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		panic(err.Error())
+	}
+	holder, err := os.Hostname()
+	if err != nil {
+		panic(err.Error())
+	}
+	return NewKubeBindingLock(clientset, namespace, holder)
+}
+
+//NewKubeBindingLock creates a BindingLock which stores a lease per locked binding in the given namespace
+func NewKubeBindingLock(clientset kubernetes.Interface, namespace string, holder string) BindingLock {
+	return &kubeBindingLock{clientset: clientset, namespace: namespace, holder: holder, duration: bindingLockDuration,
+		renewals: make(map[string]chan struct{})}
+}
+
+func (l *kubeBindingLock) TryLock(ctx context.Context, bindingID string) (bool, error) {
+	logger := requestLog(ctx)
+	leases := l.clientset.CoordinationV1beta1().Leases(l.namespace)
+	lease := l.newLease(bindingID)
+	_, err := leases.Create(lease)
+	if err == nil {
+		l.startRenewal(logger, bindingID)
+		return true, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+	existing, err := leases.Get(lease.Name, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// released in the meantime, the caller may retry
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !isExpired(existing) {
+		return false, nil
+	}
+	logger.Infof("Taking over expired lock of binding %s\n", bindingID)
+	existing.Spec = lease.Spec
+	_, err = leases.Update(existing)
+	if errors.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	l.startRenewal(logger, bindingID)
+	return true, nil
+}
+
+func (l *kubeBindingLock) Unlock(ctx context.Context, bindingID string) error {
+	l.stopRenewal(bindingID)
+	leases := l.clientset.CoordinationV1beta1().Leases(l.namespace)
+	existing, err := leases.Get(bindingLockPrefix+bindingID, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.Spec.HolderIdentity == nil || *existing.Spec.HolderIdentity != l.holder {
+		requestLog(ctx).Warnf("Lock of binding %s has been taken over\n", bindingID)
+		return nil
+	}
+	err = leases.Delete(existing.Name, &meta_v1.DeleteOptions{Preconditions: &meta_v1.Preconditions{UID: &existing.UID}})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// startRenewal keeps the lease alive while the locked operation is in flight, however long its upstream calls take
+func (l *kubeBindingLock) startRenewal(logger requestLogger, bindingID string) {
+	stop := make(chan struct{})
+	l.mutex.Lock()
+	l.renewals[bindingID] = stop
+	l.mutex.Unlock()
+	go func() {
+		ticker := time.NewTicker(l.duration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if !l.renew(logger, bindingID) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (l *kubeBindingLock) stopRenewal(bindingID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if stop, ok := l.renewals[bindingID]; ok {
+		close(stop)
+		delete(l.renewals, bindingID)
+	}
+}
+
+// renew updates the renew time of the lease and returns false once it is no longer held
+func (l *kubeBindingLock) renew(logger requestLogger, bindingID string) bool {
+	leases := l.clientset.CoordinationV1beta1().Leases(l.namespace)
+	existing, err := leases.Get(bindingLockPrefix+bindingID, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false
+	}
+	if err != nil {
+		logger.Warnf("Unable to renew lock of binding %s: %v\n", bindingID, err)
+		return true
+	}
+	if existing.Spec.HolderIdentity == nil || *existing.Spec.HolderIdentity != l.holder {
+		logger.Warnf("Lock of binding %s has been taken over\n", bindingID)
+		return false
+	}
+	now := meta_v1.NewMicroTime(time.Now())
+	existing.Spec.RenewTime = &now
+	_, err = leases.Update(existing)
+	if err != nil {
+		logger.Warnf("Unable to renew lock of binding %s: %v\n", bindingID, err)
+	}
+	return true
+}
+
+func (l *kubeBindingLock) newLease(bindingID string) *coordination.Lease {
+	holder := l.holder
+	duration := int32(l.duration / time.Second)
+	now := meta_v1.NewMicroTime(time.Now())
+	lease := &coordination.Lease{Spec: coordination.LeaseSpec{
+		HolderIdentity:       &holder,
+		LeaseDurationSeconds: &duration,
+		AcquireTime:          &now,
+		RenewTime:            &now,
+	}}
+	lease.Name = bindingLockPrefix + bindingID
+	lease.Namespace = l.namespace
+	lease.Labels = map[string]string{bindingIDLabel: bindingID}
+	return lease
+}
+
+func isExpired(lease *coordination.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	coordination "k8s.io/api/coordination/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func newTestKubeBindingLock(clientset kubernetes.Interface, holder string, duration time.Duration) *kubeBindingLock {
+	lock := NewKubeBindingLock(clientset, "catalog", holder).(*kubeBindingLock)
+	lock.duration = duration
+	return lock
+}
+
+func getLease(g *GomegaWithT, clientset kubernetes.Interface, bindingID string) *coordination.Lease {
+	lease, err := clientset.CoordinationV1beta1().Leases("catalog").Get(bindingLockPrefix+bindingID, meta_v1.GetOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	return lease
+}
+
+func TestKubeBindingLock(t *testing.T) {
+	g := NewGomegaWithT(t)
+	clientset := fake.NewSimpleClientset()
+	lock := newTestKubeBindingLock(clientset, "replica-1", time.Minute)
+	other := newTestKubeBindingLock(clientset, "replica-2", time.Minute)
+
+	locked, err := lock.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+	g.Expect(*getLease(g, clientset, "123").Spec.HolderIdentity).To(Equal("replica-1"))
+
+	locked, err = other.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeFalse())
+
+	g.Expect(lock.Unlock(context.Background(), "123")).To(Succeed())
+	locked, err = other.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+	g.Expect(other.Unlock(context.Background(), "123")).To(Succeed())
+}
+
+func TestKubeBindingLockTakesOverExpiredLease(t *testing.T) {
+	g := NewGomegaWithT(t)
+	clientset := fake.NewSimpleClientset()
+	crashed := newTestKubeBindingLock(clientset, "replica-1", time.Minute)
+	expired := crashed.newLease("123")
+	renewTime := meta_v1.NewMicroTime(time.Now().Add(-2 * time.Minute))
+	expired.Spec.RenewTime = &renewTime
+	_, err := clientset.CoordinationV1beta1().Leases("catalog").Create(expired)
+	g.Expect(err).NotTo(HaveOccurred())
+	lock := newTestKubeBindingLock(clientset, "replica-2", time.Minute)
+
+	locked, err := lock.TryLock(context.Background(), "123")
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+	lease := getLease(g, clientset, "123")
+	g.Expect(*lease.Spec.HolderIdentity).To(Equal("replica-2"))
+	g.Expect(isExpired(lease)).To(BeFalse())
+	g.Expect(lock.Unlock(context.Background(), "123")).To(Succeed())
+}
+
+func TestKubeBindingLockExpiry(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lease := newTestKubeBindingLock(fake.NewSimpleClientset(), "replica-1", time.Minute).newLease("123")
+	g.Expect(isExpired(lease)).To(BeFalse())
+
+	renewTime := meta_v1.NewMicroTime(time.Now().Add(-61 * time.Second))
+	lease.Spec.RenewTime = &renewTime
+	g.Expect(isExpired(lease)).To(BeTrue())
+
+	lease.Spec.RenewTime = nil
+	g.Expect(isExpired(lease)).To(BeTrue())
+}
+
+func TestKubeBindingLockUnlockKeepsLeaseOfOtherHolder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	clientset := fake.NewSimpleClientset()
+	lock := newTestKubeBindingLock(clientset, "replica-1", time.Minute)
+	locked, err := lock.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+	lease := getLease(g, clientset, "123")
+	holder := "replica-2"
+	lease.Spec.HolderIdentity = &holder
+	_, err = clientset.CoordinationV1beta1().Leases("catalog").Update(lease)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(lock.Unlock(context.Background(), "123")).To(Succeed())
+
+	g.Expect(*getLease(g, clientset, "123").Spec.HolderIdentity).To(Equal("replica-2"))
+}
+
+func TestKubeBindingLockRenewsLeaseWhileLocked(t *testing.T) {
+	g := NewGomegaWithT(t)
+	clientset := fake.NewSimpleClientset()
+	lock := newTestKubeBindingLock(clientset, "replica-1", time.Second)
+	other := newTestKubeBindingLock(clientset, "replica-2", time.Second)
+	locked, err := lock.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeTrue())
+
+	time.Sleep(1500 * time.Millisecond)
+
+	locked, err = other.TryLock(context.Background(), "123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(locked).To(BeFalse())
+	g.Expect(lock.Unlock(context.Background(), "123")).To(Succeed())
+}
@@ -1,21 +1,35 @@
 package router
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
 	"io/ioutil"
 	"istio.io/istio/pilot/pkg/config/kube/crd"
-	"istio.io/istio/pilot/pkg/model"
+	istioModel "istio.io/istio/pilot/pkg/model"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"istio.io/istio/pkg/log"
 	"os"
+	"reflect"
 	"strings"
 )
 
-const bindingIDLabel = "istio-broker-proxy-binding-id"
+const (
+	bindingIDLabel  = "istio-broker-proxy-binding-id"
+	instanceIDLabel = "istio-broker-proxy-instance-id"
+	bindRequestKey  = "bind-request"
+	deprovisionKey  = "deprovision"
+)
+
+var istioConfigTypes = []string{"gateway", "virtual-service", "destination-rule", "service-entry"}
 
 //NewInClusterConfigStore creates a new ConfigStore from within the cluster
 func NewInClusterConfigStore() ConfigStore {
@@ -36,12 +50,12 @@ func newKubeConfigStore(config *rest.Config, namespace string) ConfigStore {
 		panic(err.Error())
 	}
 	kubeCfgFile := os.Getenv("KUBECONFIG")
-	configClient, err := crd.NewClient(kubeCfgFile, "", model.IstioConfigTypes, "cluster.local")
+	configClient, err := crd.NewClient(kubeCfgFile, "", istioModel.IstioConfigTypes, "cluster.local")
 	if err != nil {
 		panic(err.Error())
 	}
 
-	return kubeConfigStore{clientset, namespace, configClient}
+	return kubeConfigStore{Clientset: clientset, namespace: namespace, configClient: configClient}
 }
 
 func (k kubeConfigStore) getNamespace() string {
@@ -65,43 +79,194 @@ func getNamespace() (string, error) {
 type kubeConfigStore struct {
 	*kubernetes.Clientset
 	namespace    string
-	configClient *crd.Client
+	configClient istioModel.ConfigStore
+	logger       requestLogger
+}
+
+func (k kubeConfigStore) withContext(ctx context.Context) ConfigStore {
+	k.logger = requestLog(ctx)
+	return k
 }
 
-func (k kubeConfigStore) CreateService(bindingID string, service *v1.Service) (*v1.Service, error) {
+func (k kubeConfigStore) CreateService(instanceID string, bindingID string, service *v1.Service) (*v1.Service, error) {
 	if service.Labels == nil {
 		service.Labels = make(map[string]string)
 	}
 	service.Namespace = k.namespace
-	service.Labels[bindingIDLabel] = bindingID
-	return k.CoreV1().Services(k.namespace).Create(service)
+	addLabels(service.Labels, instanceID, bindingID)
+	created, err := k.CoreV1().Services(k.namespace).Create(service)
+	if errors.IsAlreadyExists(err) {
+		existing, getErr := k.CoreV1().Services(k.namespace).Get(service.Name, meta_v1.GetOptions{})
+		if getErr != nil {
+			return nil, getErr
+		}
+		if existing.Labels[bindingIDLabel] != bindingID {
+			return nil, newConflictError("service %s already exists for binding-id %s", service.Name, existing.Labels[bindingIDLabel])
+		}
+		if !sameServiceSpec(existing.Spec, service.Spec) {
+			return nil, newConflictError("service %s already exists with different ports, selector or type", service.Name)
+		}
+		k.logger.Infof("Reusing existing service %s with cluster ip %s\n", existing.Name, existing.Spec.ClusterIP)
+		return existing, nil
+	}
+	return created, err
+}
+
+func (k kubeConfigStore) DeleteService(bindingID string, name string) error {
+	service, err := k.GetService(bindingID, name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	k.logger.Infof("kubectl -n %s delete service %s\n", k.namespace, service.Name)
+	err = k.CoreV1().Services(k.namespace).Delete(service.Name, &meta_v1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
 }
 
-func (k kubeConfigStore) CreateIstioConfig(bindingID string, configurations []model.Config) error {
+// sameServiceSpec compares the requested spec with an existing one, whose unset fields kubernetes has defaulted
+func sameServiceSpec(existing v1.ServiceSpec, requested v1.ServiceSpec) bool {
+	if serviceType(existing) != serviceType(requested) || len(existing.Ports) != len(requested.Ports) {
+		return false
+	}
+	if len(existing.Selector) != 0 || len(requested.Selector) != 0 {
+		if !reflect.DeepEqual(existing.Selector, requested.Selector) {
+			return false
+		}
+	}
+	for i := range requested.Ports {
+		if normalizeServicePort(existing.Ports[i]) != normalizeServicePort(requested.Ports[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func serviceType(spec v1.ServiceSpec) v1.ServiceType {
+	if spec.Type == "" {
+		return v1.ServiceTypeClusterIP
+	}
+	return spec.Type
+}
+
+func normalizeServicePort(port v1.ServicePort) v1.ServicePort {
+	if port.Protocol == "" {
+		port.Protocol = v1.ProtocolTCP
+	}
+	if port.TargetPort == (intstr.IntOrString{}) {
+		port.TargetPort = intstr.FromInt(int(port.Port))
+	}
+	// node ports are allocated by kubernetes
+	port.NodePort = 0
+	return port
+}
+
+func (k kubeConfigStore) GetService(bindingID string, name string) (*v1.Service, error) {
+	service, err := k.CoreV1().Services(k.namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if service.Labels[bindingIDLabel] != bindingID {
+		return nil, fmt.Errorf("service %s does not belong to binding-id %s", name, bindingID)
+	}
+	return service, nil
+}
+
+func (k kubeConfigStore) ApplyIstioConfig(instanceID string, bindingID string, configurations []istioModel.Config) error {
+	created := make([]istioModel.Config, 0, len(configurations))
 	for _, config := range configurations {
 		if config.Labels == nil {
 			config.Labels = make(map[string]string)
 		}
 		config.Namespace = k.namespace
-		config.Labels[bindingIDLabel] = bindingID
+		addLabels(config.Labels, instanceID, bindingID)
 		_, err := k.configClient.Create(config)
+		if err == nil {
+			created = append(created, config)
+		} else if errors.IsAlreadyExists(err) {
+			err = k.updateExistingIstioConfig(config)
+		}
 		if err != nil {
-			log.Errorf("error creating %s: %s\n", config.Name, err.Error())
+			k.logger.Errorf("error creating %s: %s\n", config.Name, err.Error())
+			k.rollbackIstioConfig(created)
 			return err
 		}
 	}
 	return nil
 }
 
+// rollbackIstioConfig deletes the created configurations in reverse order, reused ones belong to an earlier call
+func (k kubeConfigStore) rollbackIstioConfig(created []istioModel.Config) {
+	for i := len(created) - 1; i >= 0; i-- {
+		config := created[i]
+		k.logger.Infof("kubectl -n %s delete %s %s --ignore-not-found=true\n", k.namespace, strings.Replace(config.Type, "-", "", -1), config.Name)
+		err := k.configClient.Delete(config.Type, config.Name, k.namespace)
+		if err != nil && !errors.IsNotFound(err) {
+			k.logger.Errorf("error rolling back %s %s: %s\n", config.Type, config.Name, err.Error())
+		}
+	}
+}
+
+func (k kubeConfigStore) updateExistingIstioConfig(config istioModel.Config) error {
+	existing := k.configClient.Get(config.Type, config.Name, k.namespace)
+	if existing == nil {
+		return fmt.Errorf("%s %s already exists but can't be read", config.Type, config.Name)
+	}
+	if existing.Labels[bindingIDLabel] != config.Labels[bindingIDLabel] || !proto.Equal(existing.Spec, config.Spec) {
+		return newConflictError("%s %s already exists with different parameters", config.Type, config.Name)
+	}
+	if reflect.DeepEqual(existing.Labels, config.Labels) {
+		k.logger.Infof("Reusing identical %s %s\n", config.Type, config.Name)
+		return nil
+	}
+	config.ResourceVersion = existing.ResourceVersion
+	_, err := k.configClient.Update(config)
+	return err
+}
+
+func addLabels(labels map[string]string, instanceID string, bindingID string) {
+	labels[bindingIDLabel] = bindingID
+	if instanceID != "" {
+		labels[instanceIDLabel] = instanceID
+	}
+}
+
 func (k kubeConfigStore) DeleteBinding(bindingID string) error {
-	log.Infof("kubectl -n %s delete services -l %s=%s\n", k.namespace, bindingIDLabel, bindingID)
+	return k.deleteByLabel(bindingIDLabel, bindingID)
+}
+
+func (k kubeConfigStore) DeleteInstance(instanceID string) error {
+	err := k.deleteByLabel(instanceIDLabel, instanceID)
+	if err != nil {
+		return err
+	}
+	k.logger.Infof("kubectl -n %s delete configmaps -l %s=%s\n", k.namespace, instanceIDLabel, instanceID)
+	list, err := k.CoreV1().ConfigMaps(k.namespace).List(meta_v1.ListOptions{LabelSelector: instanceIDLabel + "=" + instanceID})
+	if err != nil {
+		return err
+	}
+	for _, configMap := range list.Items {
+		err := k.CoreV1().ConfigMaps(k.namespace).Delete(configMap.Name, &meta_v1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k kubeConfigStore) deleteByLabel(label string, value string) error {
+	k.logger.Infof("kubectl -n %s delete services -l %s=%s\n", k.namespace, label, value)
 	services := k.CoreV1().Services(k.namespace)
-	list, err := services.List(meta_v1.ListOptions{LabelSelector: bindingIDLabel + "=" + bindingID})
+	list, err := services.List(meta_v1.ListOptions{LabelSelector: label + "=" + value})
 	if err != nil {
 		return err
 	}
 	for _, service := range list.Items {
-		log.Infof("kubectl -n %s delete service -l %s=%s --ignore-not-found=true\n", k.namespace,  bindingIDLabel, bindingID)
+		k.logger.Infof("kubectl -n %s delete service %s --ignore-not-found=true\n", k.namespace, service.Name)
 		err := k.CoreV1().Services(k.namespace).Delete(service.Name, &meta_v1.DeleteOptions{})
 		if err != nil {
 			if ! errors.IsNotFound(err) {
@@ -109,14 +274,14 @@ func (k kubeConfigStore) DeleteBinding(bindingID string) error {
 			}
 		}
 	}
-	for _, typ := range []string{"gateway", "virtual-service", "destination-rule", "service-entry"} {
-		log.Infof("kubectl -n %s delete %s -l %s=%s --ignore-not-found=true\n", k.namespace, strings.Replace(typ,"-","",-1), bindingIDLabel, bindingID)
+	for _, typ := range istioConfigTypes {
+		k.logger.Infof("kubectl -n %s delete %s -l %s=%s --ignore-not-found=true\n", k.namespace, strings.Replace(typ, "-", "", -1), label, value)
 		configs, err := k.configClient.List(typ, k.namespace)
 		if err != nil {
 			return err
 		}
 		for _, config := range configs {
-			if config.Labels != nil && config.Labels[bindingIDLabel] == bindingID {
+			if config.Labels != nil && config.Labels[label] == value {
 				err = k.configClient.Delete(typ, config.Name, k.namespace)
 				if err != nil {
 					if ! errors.IsNotFound(err) {
@@ -130,6 +295,100 @@ func (k kubeConfigStore) DeleteBinding(bindingID string) error {
 	return nil
 }
 
+func (k kubeConfigStore) StoreBindRequest(instanceID string, bindingID string, request model.BindRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	configMap := &v1.ConfigMap{Data: map[string]string{bindRequestKey: string(data)}}
+	configMap.Name = bindRequestName(bindingID)
+	configMap.Namespace = k.namespace
+	configMap.Labels = make(map[string]string)
+	addLabels(configMap.Labels, instanceID, bindingID)
+	k.logger.Infof("kubectl -n %s create configmap %s\n", k.namespace, configMap.Name)
+	_, err = k.CoreV1().ConfigMaps(k.namespace).Create(configMap)
+	return err
+}
+
+func (k kubeConfigStore) LoadBindRequest(bindingID string) (*model.BindRequest, error) {
+	configMap, err := k.CoreV1().ConfigMaps(k.namespace).Get(bindRequestName(bindingID), meta_v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var request model.BindRequest
+	err = json.Unmarshal([]byte(configMap.Data[bindRequestKey]), &request)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (k kubeConfigStore) DeleteBindRequest(bindingID string) error {
+	k.logger.Infof("kubectl -n %s delete configmap %s --ignore-not-found=true\n", k.namespace, bindRequestName(bindingID))
+	err := k.CoreV1().ConfigMaps(k.namespace).Delete(bindRequestName(bindingID), &meta_v1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// StoreDeprovision creates a config map with the instance-id label, so that DeleteInstance removes it
+func (k kubeConfigStore) StoreDeprovision(instanceID string) error {
+	configMap := &v1.ConfigMap{}
+	configMap.Name = deprovisionKey + "-" + instanceID
+	configMap.Namespace = k.namespace
+	configMap.Labels = map[string]string{instanceIDLabel: instanceID}
+	k.logger.Infof("kubectl -n %s create configmap %s\n", k.namespace, configMap.Name)
+	_, err := k.CoreV1().ConfigMaps(k.namespace).Create(configMap)
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func (k kubeConfigStore) IsDeprovisioning(instanceID string) (bool, error) {
+	_, err := k.CoreV1().ConfigMaps(k.namespace).Get(deprovisionKey+"-"+instanceID, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (k kubeConfigStore) CountBindings() (int, error) {
+	bindings := make(map[string]bool)
+	for _, typ := range istioConfigTypes {
+		configs, err := k.configClient.List(typ, k.namespace)
+		if err != nil {
+			return 0, err
+		}
+		for _, config := range configs {
+			if isBinding(config.Labels[bindingIDLabel]) {
+				bindings[config.Labels[bindingIDLabel]] = true
+			}
+		}
+	}
+	return len(bindings), nil
+}
+
+func (k kubeConfigStore) Health() error {
+	_, err := k.CoreV1().Services(k.namespace).List(meta_v1.ListOptions{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("unable to list services: %v", err)
+	}
+	_, err = k.configClient.List(istioConfigTypes[0], k.namespace)
+	if err != nil {
+		return fmt.Errorf("unable to list istio configuration: %v", err)
+	}
+	return nil
+}
+
+func bindRequestName(bindingID string) string {
+	return bindRequestKey + "-" + bindingID
+}
+
 //NewExternKubeConfigStore creates a new ConfigStore using the KUBECONFIG env variable
 func NewExternKubeConfigStore(namespace string) ConfigStore {
 	clientcmd.ClusterDefaults.Server = ""
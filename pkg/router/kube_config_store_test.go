@@ -0,0 +1,188 @@
+package router
+
+import (
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/config"
+	. "github.com/onsi/gomega"
+	istioModel "istio.io/istio/pilot/pkg/model"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+// fakeIstioConfigClient keeps the configurations in memory and fails the create call at the position failCreateAt
+type fakeIstioConfigClient struct {
+	configs      map[string]istioModel.Config
+	failCreateAt int
+	createCalls  int
+	deleted      []string
+}
+
+func newFakeIstioConfigClient(failCreateAt int) *fakeIstioConfigClient {
+	return &fakeIstioConfigClient{configs: make(map[string]istioModel.Config), failCreateAt: failCreateAt}
+}
+
+func (f *fakeIstioConfigClient) ConfigDescriptor() istioModel.ConfigDescriptor {
+	return istioModel.IstioConfigTypes
+}
+
+func (f *fakeIstioConfigClient) Get(typ, name, namespace string) *istioModel.Config {
+	config, ok := f.configs[istioModel.Key(typ, name, namespace)]
+	if !ok {
+		return nil
+	}
+	return &config
+}
+
+func (f *fakeIstioConfigClient) List(typ, namespace string) ([]istioModel.Config, error) {
+	var configs []istioModel.Config
+	for _, config := range f.configs {
+		if config.Type == typ && config.Namespace == namespace {
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+func (f *fakeIstioConfigClient) Create(config istioModel.Config) (string, error) {
+	f.createCalls++
+	if f.createCalls-1 == f.failCreateAt {
+		return "", fmt.Errorf("unable to create %s %s", config.Type, config.Name)
+	}
+	if _, ok := f.configs[config.Key()]; ok {
+		return "", errors.NewAlreadyExists(schema.GroupResource{Resource: config.Type}, config.Name)
+	}
+	f.configs[config.Key()] = config
+	return "1", nil
+}
+
+func (f *fakeIstioConfigClient) Update(config istioModel.Config) (string, error) {
+	if _, ok := f.configs[config.Key()]; !ok {
+		return "", errors.NewNotFound(schema.GroupResource{Resource: config.Type}, config.Name)
+	}
+	f.configs[config.Key()] = config
+	return "2", nil
+}
+
+func (f *fakeIstioConfigClient) Delete(typ, name, namespace string) error {
+	key := istioModel.Key(typ, name, namespace)
+	if _, ok := f.configs[key]; !ok {
+		return errors.NewNotFound(schema.GroupResource{Resource: typ}, name)
+	}
+	delete(f.configs, key)
+	f.deleted = append(f.deleted, typ+"/"+name)
+	return nil
+}
+
+func newTestKubeConfigStore(client *fakeIstioConfigClient) kubeConfigStore {
+	return kubeConfigStore{namespace: "catalog", configClient: client}
+}
+
+func clientConfigurations() []istioModel.Config {
+	return config.CreateEntriesForExternalServiceClient("svc-0-binding-id", "postgres.services.cf.dev01.aws.istio.sapcloud.io", "10.0.0.1", 9000, "istio.sapcloud.io")
+}
+
+func TestKubeConfigStoreAppliesAllConfigurations(t *testing.T) {
+	g := NewGomegaWithT(t)
+	client := newFakeIstioConfigClient(-1)
+	configurations := clientConfigurations()
+
+	err := newTestKubeConfigStore(client).ApplyIstioConfig("instance-id", "binding-id", configurations)
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(client.configs).To(HaveLen(len(configurations)))
+	for _, config := range client.configs {
+		g.Expect(config.Namespace).To(Equal("catalog"))
+		g.Expect(config.Labels).To(HaveKeyWithValue(bindingIDLabel, "binding-id"))
+		g.Expect(config.Labels).To(HaveKeyWithValue(instanceIDLabel, "instance-id"))
+	}
+}
+
+func TestKubeConfigStoreRollsBackOnFailureAtEveryPosition(t *testing.T) {
+	configurations := clientConfigurations()
+	for position := range configurations {
+		t.Run(fmt.Sprintf("position %d", position), func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			client := newFakeIstioConfigClient(position)
+
+			err := newTestKubeConfigStore(client).ApplyIstioConfig("instance-id", "binding-id", configurations)
+
+			g.Expect(err).To(MatchError(fmt.Sprintf("unable to create %s %s", configurations[position].Type, configurations[position].Name)))
+			g.Expect(client.configs).To(BeEmpty())
+			g.Expect(client.deleted).To(HaveLen(position))
+			for i, name := range client.deleted {
+				// the configurations are removed in reverse order
+				created := configurations[position-1-i]
+				g.Expect(name).To(Equal(created.Type + "/" + created.Name))
+			}
+		})
+	}
+}
+
+func TestKubeConfigStoreRollbackKeepsReusedConfigurations(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configurations := clientConfigurations()
+	client := newFakeIstioConfigClient(-1)
+	store := newTestKubeConfigStore(client)
+	g.Expect(store.ApplyIstioConfig("instance-id", "binding-id", configurations[:1])).To(Succeed())
+
+	client.failCreateAt = client.createCalls + len(configurations) - 1
+	err := store.ApplyIstioConfig("instance-id", "binding-id", configurations)
+
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(client.configs).To(HaveLen(1))
+	g.Expect(client.Get(configurations[0].Type, configurations[0].Name, "catalog")).NotTo(BeNil())
+	g.Expect(client.deleted).To(HaveLen(len(configurations) - 2))
+}
+
+func TestKubeConfigStoreRollsBackOnConflict(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configurations := clientConfigurations()
+	client := newFakeIstioConfigClient(-1)
+	store := newTestKubeConfigStore(client)
+	last := len(configurations) - 1
+	g.Expect(store.ApplyIstioConfig("other-instance-id", "other-binding-id", configurations[last:])).To(Succeed())
+
+	err := store.ApplyIstioConfig("instance-id", "binding-id", configurations)
+
+	g.Expect(isConflict(err)).To(BeTrue())
+	g.Expect(client.configs).To(HaveLen(1))
+	g.Expect(client.Get(configurations[last].Type, configurations[last].Name, "catalog").Labels).To(HaveKeyWithValue(bindingIDLabel, "other-binding-id"))
+}
+
+func TestSameServiceSpecIgnoresDefaults(t *testing.T) {
+	g := NewGomegaWithT(t)
+	requested := v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 5555, TargetPort: intstr.FromInt(5555)}}}
+	existing := v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, ClusterIP: "10.0.0.1", Selector: map[string]string{},
+		Ports: []v1.ServicePort{{Port: 5555, TargetPort: intstr.FromInt(5555), Protocol: v1.ProtocolTCP}}}
+
+	g.Expect(sameServiceSpec(existing, requested)).To(BeTrue())
+}
+
+func TestSameServiceSpecDetectsDifferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+	requested := v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 5555, TargetPort: intstr.FromInt(5555)}}}
+
+	otherPort := v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 6666, TargetPort: intstr.FromInt(5555)}}}
+	g.Expect(sameServiceSpec(otherPort, requested)).To(BeFalse())
+	otherSelector := v1.ServiceSpec{Selector: map[string]string{"app": "postgres"}, Ports: requested.Ports}
+	g.Expect(sameServiceSpec(otherSelector, requested)).To(BeFalse())
+	otherType := v1.ServiceSpec{Type: v1.ServiceTypeNodePort, Ports: requested.Ports}
+	g.Expect(sameServiceSpec(otherType, requested)).To(BeFalse())
+}
+
+func TestKubeConfigStoreCountBindings(t *testing.T) {
+	g := NewGomegaWithT(t)
+	store := newTestKubeConfigStore(newFakeIstioConfigClient(-1))
+	configurations := clientConfigurations()
+	g.Expect(store.ApplyIstioConfig("instance-id", "binding-id", configurations[:1])).To(Succeed())
+	g.Expect(store.ApplyIstioConfig("", "binding-without-instance", configurations[1:2])).To(Succeed())
+	g.Expect(store.ApplyIstioConfig("", producerBindingID, configurations[2:3])).To(Succeed())
+
+	count, err := store.CountBindings()
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(count).To(Equal(2))
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"istio.io/istio/pkg/log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	metricsNamespace = "istio_broker_proxy"
+	metricsEndpoint  = "/metrics"
+	defaultBrokerID  = "default"
+	unknownBrokerID  = "unknown"
+)
+
+var (
+	osbOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "osb_operation_duration_seconds",
+		Help:      "Duration of the open service broker operations by broker and response status",
+	}, []string{"operation", "broker", "status"})
+	interceptorRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "interceptor_rejections_total",
+		Help:      "Requests and responses rejected by the interceptor, e.g. because of an InvalidConsumerNetworkProfile",
+	}, []string{"operation", "reason"})
+	configStoreDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_store_duration_seconds",
+		Help:      "Duration of the calls to the config store",
+	}, []string{"method"})
+	configStoreErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "config_store_errors_total",
+		Help:      "Failed calls to the config store",
+	}, []string{"method"})
+	activeBindings prometheus.Collector
+)
+
+func init() {
+	prometheus.MustRegister(osbOperationDuration, interceptorRejections, configStoreDuration, configStoreErrors)
+}
+
+func observeOperation(operation string, proxies *brokerProxies) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		broker := proxies.metricsLabel(ctx.Params.ByName("broker_id"))
+		osbOperationDuration.WithLabelValues(operation, broker, strconv.Itoa(ctx.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+func countRejection(operation string, err error) error {
+	if err != nil {
+		interceptorRejections.WithLabelValues(operation, model.HTTPErrorFromError(err, http.StatusInternalServerError).ErrorMsg).Inc()
+	}
+	return err
+}
+
+// registerActiveBindings exposes the number of bindings in the store, which is counted on each scrape
+func registerActiveBindings(configStore ConfigStore) {
+	if activeBindings != nil {
+		prometheus.Unregister(activeBindings)
+	}
+	activeBindings = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "active_bindings",
+		Help:      "Bindings known to the config store",
+	}, func() float64 {
+		count, err := configStore.CountBindings()
+		if err != nil {
+			log.Warnf("Unable to count bindings: %v\n", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+	prometheus.MustRegister(activeBindings)
+}
+
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(prometheus.UninstrumentedHandler())
+}
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	. "github.com/onsi/gomega"
+	istioModel "istio.io/istio/pilot/pkg/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func scrapeMetrics(router *gin.Engine) string {
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/metrics", bytes.NewReader(make([]byte, 0)))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response.Body.String()
+}
+
+func TestOsbOperationsAreMeasured(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub := newHandlerStub(http.StatusOK, []byte(`{"services": []}`))
+	server, routerConfig := injectClientStub(handlerStub)
+
+	defer server.Close()
+
+	routerConfig.Brokers = map[string]BrokerConfig{"metrics-broker": {ForwardURL: "http://broker-a.xx"}}
+	router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v1/osb/metrics-broker/v2/catalog", bytes.NewReader(make([]byte, 0)))
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	g.Expect(scrapeMetrics(router)).To(ContainSubstring(`istio_broker_proxy_osb_operation_duration_seconds_count{broker="metrics-broker",operation="catalog",status="200"} 1`))
+}
+
+func TestUnregisteredBrokersAreMeasuredAsUnknown(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub := newHandlerStub(http.StatusOK, []byte(`{"services": []}`))
+	server, routerConfig := injectClientStub(handlerStub)
+
+	defer server.Close()
+
+	routerConfig.Brokers = map[string]BrokerConfig{"metrics-broker": {ForwardURL: "http://broker-a.xx"}}
+	router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v1/osb/random-1234/v2/catalog", bytes.NewReader(make([]byte, 0)))
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	metrics := scrapeMetrics(router)
+	g.Expect(metrics).To(ContainSubstring(`istio_broker_proxy_osb_operation_duration_seconds_count{broker="unknown",operation="catalog",status="404"}`))
+	g.Expect(metrics).NotTo(ContainSubstring(`broker="random-1234"`))
+}
+
+func TestInterceptorRejectionsAreCounted(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub := newHandlerStub(http.StatusOK, []byte(`{}`))
+	server, routerConfig := injectClientStub(handlerStub)
+
+	defer server.Close()
+
+	router := SetupRouter(ProducerInterceptor{NetworkProfile: "urn:local.test:public"}, *routerConfig)
+	request, _ := http.NewRequest(http.MethodPut, "https://blahblubs.org/v2/service_instances/123", bytes.NewReader([]byte(`{"network_profiles": [{"id": "urn:other:public"}]}`)))
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	g.Expect(scrapeMetrics(router)).To(ContainSubstring(`istio_broker_proxy_interceptor_rejections_total{operation="provision",reason="InvalidConsumerNetworkProfile"}`))
+}
+
+func TestConfigStoreCallsAreMeasured(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mock := NewMockConfigStore()
+	configStore := NewInstrumentedConfigStore(mock)
+
+	err := configStore.ApplyIstioConfig("instance-1", "metrics-binding-1", []istioModel.Config{{ConfigMeta: istioModel.ConfigMeta{Type: "service-entry", Name: "metrics-1"}}})
+	g.Expect(err).NotTo(HaveOccurred())
+	err = configStore.ApplyIstioConfig("instance-1", "metrics-binding-2", []istioModel.Config{{ConfigMeta: istioModel.ConfigMeta{Type: "service-entry", Name: "metrics-2"}}})
+	g.Expect(err).NotTo(HaveOccurred())
+	mock.(*MockConfigStore).CreateServiceErr = errors.New("create service failed")
+	_, err = configStore.CreateService("instance-1", "metrics-binding-3", nil)
+	g.Expect(err).To(HaveOccurred())
+
+	metrics := scrapeMetrics(SetupRouter(&noOpInterceptor{}, Config{}))
+	g.Expect(metrics).To(ContainSubstring(`istio_broker_proxy_config_store_duration_seconds_count{method="ApplyIstioConfig"}`))
+	g.Expect(metrics).To(ContainSubstring(`istio_broker_proxy_config_store_errors_total{method="CreateService"}`))
+	g.Expect(metrics).To(ContainSubstring("istio_broker_proxy_active_bindings 2"))
+}
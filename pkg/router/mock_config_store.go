@@ -3,6 +3,8 @@ package router
 import (
 	"errors"
 	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gogo/protobuf/proto"
 	istioModel "istio.io/istio/pilot/pkg/model"
 	"k8s.io/api/core/v1"
 )
@@ -14,56 +16,108 @@ type MockConfigStore struct {
 	CreateServiceErr     error
 	CreateObjectErr      error
 	CreateObjectErrCount int
+	HealthErr            error
 	DeletedServices      []string
 	DeletedIstioConfigs  []string
+	BindRequests         map[string]model.BindRequest
+	bindRequestInstances map[string]string
+	deprovisions         map[string]bool
 }
 
+
 //CreateService stores the service that would be created
-func (m *MockConfigStore) CreateService(bindingID string, service *v1.Service) (*v1.Service, error) {
+func (m *MockConfigStore) CreateService(instanceID string, bindingID string, service *v1.Service) (*v1.Service, error) {
 	if m.CreateServiceErr != nil {
 		return nil, m.CreateServiceErr
 	}
 	if service.Labels == nil {
 		service.Labels = make(map[string]string)
 	}
-	service.Labels["istio-broker-proxy-binding-id"] = bindingID
+	addLabels(service.Labels, instanceID, bindingID)
+	for _, existing := range m.CreatedServices {
+		if existing.Name == service.Name {
+			if existing.Labels[bindingIDLabel] != bindingID {
+				return nil, newConflictError("service %s already exists", service.Name)
+			}
+			return existing, nil
+		}
+	}
 	m.CreatedServices = append(m.CreatedServices, service)
 	service.Spec.ClusterIP = m.ClusterIP
 	return service, nil
 }
 
+//GetService returns a service which has been created via this store
+func (m *MockConfigStore) GetService(bindingID string, name string) (*v1.Service, error) {
+	for _, service := range m.CreatedServices {
+		if service.Name == name && service.Labels[bindingIDLabel] == bindingID {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("service %s not found", name)
+}
+
+//DeleteService removes a service which has been created via this store
+func (m *MockConfigStore) DeleteService(bindingID string, name string) error {
+	for index, service := range m.CreatedServices {
+		if service.Name == name && service.Labels[bindingIDLabel] == bindingID {
+			m.DeletedServices = append(m.DeletedServices, service.Name)
+			m.CreatedServices = append(m.CreatedServices[:index], m.CreatedServices[index+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
 func (m *MockConfigStore) getNamespace() string {
 	return "catalog"
 }
 
-//CreateIstioConfig stores the configs that would be created
-func (m *MockConfigStore) CreateIstioConfig(bindingID string, configs []istioModel.Config) error {
+//ApplyIstioConfig stores the configs that would be created, none of them if one fails
+func (m *MockConfigStore) ApplyIstioConfig(instanceID string, bindingID string, configs []istioModel.Config) error {
+	applied := len(m.CreatedIstioConfigs)
 	for _, config := range configs {
 		if config.Labels == nil {
 			config.Labels = make(map[string]string)
 		}
-		config.Labels["istio-broker-proxy-binding-id"] = bindingID
+		addLabels(config.Labels, instanceID, bindingID)
 		if m.CreateObjectErr != nil && m.CreateObjectErrCount == len(m.CreatedIstioConfigs) {
+			m.CreatedIstioConfigs = m.CreatedIstioConfigs[:applied]
 			return m.CreateObjectErr
 		}
-		m.CreatedIstioConfigs = append(m.CreatedIstioConfigs, config)
+		existing := m.findIstioConfig(config.Type, config.Name)
+		if existing == nil {
+			m.CreatedIstioConfigs = append(m.CreatedIstioConfigs, config)
+		} else if existing.Labels[bindingIDLabel] != bindingID || !proto.Equal(existing.Spec, config.Spec) {
+			m.CreatedIstioConfigs = m.CreatedIstioConfigs[:applied]
+			return newConflictError("%s %s already exists with different parameters", config.Type, config.Name)
+		}
+	}
+	return nil
+}
+
+func (m *MockConfigStore) findIstioConfig(typ string, name string) *istioModel.Config {
+	for i := range m.CreatedIstioConfigs {
+		if m.CreatedIstioConfigs[i].Type == typ && m.CreatedIstioConfigs[i].Name == name {
+			return &m.CreatedIstioConfigs[i]
+		}
 	}
 	return nil
 }
 
-func (m *MockConfigStore) deleteService(bindingID string) error {
+func (m *MockConfigStore) deleteService(label string, value string) error {
 	found := 0
 	services := append([]*v1.Service{}, m.CreatedServices...)
 
 	for index, c := range services {
-		if c.Labels["istio-broker-proxy-binding-id"] == bindingID {
+		if c.Labels[label] == value {
 			m.DeletedServices = append(m.DeletedServices, c.Name)
 			m.CreatedServices = append(m.CreatedServices[:index-found], m.CreatedServices[index-found+1:]...)
 			found++
 		}
 	}
 	if found == 0 {
-		errorMsg := fmt.Sprintf("error binding-id %s not found", bindingID)
+		errorMsg := fmt.Sprintf("error %s %s not found", label, value)
 		return errors.New(errorMsg)
 	}
 	return nil
@@ -71,20 +125,94 @@ func (m *MockConfigStore) deleteService(bindingID string) error {
 
 //DeleteBinding stores the objects that would have been deleted if they have been created via this store
 func (m *MockConfigStore) DeleteBinding(bindingID string) error {
+	return m.deleteByLabel(bindingIDLabel, bindingID)
+}
+
+//DeleteInstance stores the objects of all bindings of an instance that would have been deleted
+func (m *MockConfigStore) DeleteInstance(instanceID string) error {
+	for bindingID, id := range m.bindRequestInstances {
+		if id == instanceID {
+			m.DeleteBindRequest(bindingID)
+		}
+	}
+	delete(m.deprovisions, instanceID)
+	// an instance without bindings is not an error
+	m.deleteByLabel(instanceIDLabel, instanceID)
+	return nil
+}
+
+//CountBindings returns the number of bindings with istio configs created via this store
+func (m *MockConfigStore) CountBindings() (int, error) {
+	bindings := make(map[string]bool)
+	for _, config := range m.CreatedIstioConfigs {
+		if isBinding(config.Labels[bindingIDLabel]) {
+			bindings[config.Labels[bindingIDLabel]] = true
+		}
+	}
+	return len(bindings), nil
+}
+
+//Health returns the HealthErr
+func (m *MockConfigStore) Health() error {
+	return m.HealthErr
+}
+
+func (m *MockConfigStore) deleteByLabel(label string, value string) error {
 	found := 0
 	configs := append([]istioModel.Config{}, m.CreatedIstioConfigs...)
 	for index, c := range configs {
-		if c.Labels["istio-broker-proxy-binding-id"] == bindingID {
+		if c.Labels[label] == value {
 			m.DeletedIstioConfigs = append(m.DeletedIstioConfigs, c.Type+":"+c.Name)
 			m.CreatedIstioConfigs = append(m.CreatedIstioConfigs[:index-found], m.CreatedIstioConfigs[index-found+1:]...)
 			found++
 		}
 	}
 	if found == 0 {
-		errorMsg := fmt.Sprintf("error binding-id %s not found", bindingID)
+		errorMsg := fmt.Sprintf("error %s %s not found", label, value)
 		return errors.New(errorMsg)
 	}
-	return m.deleteService(bindingID)
+	return m.deleteService(label, value)
+}
+
+//StoreBindRequest keeps the bind request in memory
+func (m *MockConfigStore) StoreBindRequest(instanceID string, bindingID string, request model.BindRequest) error {
+	if m.BindRequests == nil {
+		m.BindRequests = make(map[string]model.BindRequest)
+		m.bindRequestInstances = make(map[string]string)
+	}
+	m.BindRequests[bindingID] = request
+	m.bindRequestInstances[bindingID] = instanceID
+	return nil
+}
+
+//LoadBindRequest returns a previously stored bind request or nil
+func (m *MockConfigStore) LoadBindRequest(bindingID string) (*model.BindRequest, error) {
+	request, ok := m.BindRequests[bindingID]
+	if !ok {
+		return nil, nil
+	}
+	return &request, nil
+}
+
+//DeleteBindRequest removes a previously stored bind request
+func (m *MockConfigStore) DeleteBindRequest(bindingID string) error {
+	delete(m.BindRequests, bindingID)
+	delete(m.bindRequestInstances, bindingID)
+	return nil
+}
+
+//StoreDeprovision keeps the asynchronous deprovisioning in memory
+func (m *MockConfigStore) StoreDeprovision(instanceID string) error {
+	if m.deprovisions == nil {
+		m.deprovisions = make(map[string]bool)
+	}
+	m.deprovisions[instanceID] = true
+	return nil
+}
+
+//IsDeprovisioning checks for a deprovisioning stored before
+func (m *MockConfigStore) IsDeprovisioning(instanceID string) (bool, error) {
+	return m.deprovisions[instanceID], nil
 }
 
 //NewMockConfigStore create a new ConfigStore with mocking capabilities
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/Peripli/istio-broker-proxy/pkg/api"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"net/url"
 )
 
 type osbClient struct {
@@ -18,31 +19,71 @@ func (client *osbClient) adaptCredentials(credentials model.Credentials, mapping
 	return &bindResponse, err
 }
 
-func (client *osbClient) getCatalog() (*model.Catalog, error) {
+func (client *osbClient) getCatalog() (*model.Catalog, api.RESTResponse, error) {
 	var catalog model.Catalog
-	err := client.Get().
-		Do().
-		Into(&catalog)
-	return &catalog, err
+	response := client.Get().
+		Do()
+	err := response.Into(&catalog)
+	return &catalog, response, err
 }
 
-func (client *osbClient) bind(request *model.BindRequest) (*model.BindResponse, error) {
+func (client *osbClient) bind(request *model.BindRequest) (*model.BindResponse, api.RESTResponse, error) {
 	var bindResponse model.BindResponse
+	response := client.Put(request).
+		Do()
+	err := response.Into(&bindResponse)
+	return &bindResponse, response, err
+}
 
-	err := client.Put(request).
-		Do().
-		Into(&bindResponse)
+func (client *osbClient) fetchBinding() (*model.BindResponse, api.RESTResponse, error) {
+	var bindResponse model.BindResponse
+	response := client.Get().
+		Do()
+	err := response.Into(&bindResponse)
+	return &bindResponse, response, err
+}
 
-	return &bindResponse, err
+func (client *osbClient) unbind() (api.RESTResponse, error) {
+	response := client.Delete().
+		Do()
+	return response, response.Error()
 }
 
-func (client *osbClient) unbind() error {
-	return client.Delete().
-		Do().
-		Error()
+func (client *osbClient) unbindWithQuery(query url.Values) (api.RESTResponse, error) {
+	response := client.Delete().
+		SetQuery(query).
+		Do()
+	return response, response.Error()
+}
+
+func (client *osbClient) deprovision() (map[string]interface{}, api.RESTResponse, error) {
+	var deprovisionResponse map[string]interface{}
+	response := client.Delete().
+		Do()
+	err := response.Into(&deprovisionResponse)
+	return deprovisionResponse, response, err
+}
+
+func (client *osbClient) provision(request *model.ProvisionRequest) (*model.ProvisionResponse, api.RESTResponse, error) {
+	var provisionResponse model.ProvisionResponse
+	response := client.Put(request).
+		Do()
+	err := response.Into(&provisionResponse)
+	return &provisionResponse, response, err
+}
+
+func (client *osbClient) update(request *model.UpdateRequest) (*model.UpdateResponse, api.RESTResponse, error) {
+	var updateResponse model.UpdateResponse
+	response := client.Patch(request).
+		Do()
+	err := response.Into(&updateResponse)
+	return &updateResponse, response, err
 }
 
-func (client *osbClient) provision(request *model.ProvisionRequest) (response *model.ProvisionResponse, err error) {
-	err = client.Put(request).Do().Into(&response)
-	return
+func (client *osbClient) getLastOperation() (*model.LastOperationResponse, api.RESTResponse, error) {
+	var lastOperation model.LastOperationResponse
+	response := client.Get().
+		Do()
+	err := response.Into(&lastOperation)
+	return &lastOperation, response, err
 }
@@ -109,7 +109,7 @@ func TestGetCatalog(t *testing.T) {
 	server, routerConfig := injectClientStub(handlerStub)
 	defer server.Close()
 	client := osbClient{&restClient{routerConfig.HTTPClientFactory(&http.Transport{}), &http.Request{URL: &url.URL{}}, *routerConfig}}
-	catalog, err := client.getCatalog()
+	catalog, _, err := client.getCatalog()
 
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(catalog).NotTo(BeNil())
@@ -129,7 +129,7 @@ func TestGetCatalogWithoutUpstreamServer(t *testing.T) {
 	server, routerConfig := injectClientStub(handlerStub)
 	defer server.Close()
 	client := osbClient{&restClient{routerConfig.HTTPClientFactory(&http.Transport{}), &http.Request{URL: &url.URL{}}, *routerConfig}}
-	_, err := client.getCatalog()
+	_, _, err := client.getCatalog()
 
 	g.Expect(err).To(HaveOccurred())
 }
@@ -143,7 +143,7 @@ func TestGetCatalogWithInvalidCatalog(t *testing.T) {
 	server, routerConfig := injectClientStub(handlerStub)
 	defer server.Close()
 	client := osbClient{&restClient{routerConfig.HTTPClientFactory(&http.Transport{}), &http.Request{URL: &url.URL{}}, *routerConfig}}
-	_, err := client.getCatalog()
+	_, _, err := client.getCatalog()
 
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(ContainSubstring("Can't unmarshal response from"))
@@ -158,7 +158,7 @@ func TestGetCatalogWithBadRequest(t *testing.T) {
 	server, routerConfig := injectClientStub(handlerStub)
 	defer server.Close()
 	client := osbClient{&restClient{routerConfig.HTTPClientFactory(&http.Transport{}), &http.Request{URL: &url.URL{}}, *routerConfig}}
-	_, err := client.getCatalog()
+	_, _, err := client.getCatalog()
 
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(Equal("error: 'BadRequest', description: ': from call to GET http://xxxxx.xx'"))
@@ -174,7 +174,7 @@ func TestUnbind(t *testing.T) {
 	defer server.Close()
 	client := osbClient{&restClient{routerConfig.HTTPClientFactory(&http.Transport{}),
 		&http.Request{URL: &url.URL{Host: "yyyy:123", Path: "/v2/service_instances/1/service_bindings/2", RawQuery: "query_parameter=value"}}, *routerConfig}}
-	err := client.unbind()
+	_, err := client.unbind()
 
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(handlerStub.spy.url).To(Equal("http://xxxxx.xx/v2/service_instances/1/service_bindings/2?query_parameter=value"))
@@ -1,14 +1,17 @@
 package router
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/Peripli/istio-broker-proxy/pkg/config"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
 	"github.com/Peripli/istio-broker-proxy/pkg/profiles"
-	"istio.io/istio/pkg/log"
 	"net/http"
 )
 
+// producerBindingID labels the configuration of the route to the producer itself
+const producerBindingID = "istio-broker"
+
 //ProducerInterceptor contains config for the producer side
 type ProducerInterceptor struct {
 	LoadBalancerPort  int
@@ -19,13 +22,41 @@ type ProducerInterceptor struct {
 	PlanMetaData      string
 	NetworkProfile    string
 	ConfigStore       ConfigStore
+	BrokerAPIVersion  model.BrokerAPIVersion
+	// AuditLog records provisions, bindings and unbindings, nil disables the audit
+	AuditLog AuditLog
+	logger   requestLogger
+	ctx      context.Context
+}
+
+//WithBrokerAPIVersion see interface definition
+func (c ProducerInterceptor) WithBrokerAPIVersion(version model.BrokerAPIVersion) ServiceBrokerInterceptor {
+	c.BrokerAPIVersion = version
+	return c
+}
+
+//WithContext see interface definition
+func (c ProducerInterceptor) WithContext(ctx context.Context) ServiceBrokerInterceptor {
+	c.ConfigStore = newTracedConfigStore(ctx, c.ConfigStore)
+	c.logger = requestLog(ctx)
+	c.ctx = ctx
+	return c
 }
 
 //PreProvision see interface definition
 func (c ProducerInterceptor) PreProvision(request model.ProvisionRequest) (*model.ProvisionRequest, error) {
+	err := c.checkConsumerNetworkProfiles(request.NetworkProfiles)
+	if err != nil {
+		return nil, err
+	}
+	request.NetworkProfiles = make([]model.NetworkProfile,0)
+	return &request, nil
+}
+
+func (c ProducerInterceptor) checkConsumerNetworkProfiles(profiles []model.NetworkProfile) error {
 	matched := 0
 	unmatched := 0
-	for _, profile := range request.NetworkProfiles{
+	for _, profile := range profiles {
 		if profile.ID == c.NetworkProfile {
 			matched++
 		} else {
@@ -33,27 +64,68 @@ func (c ProducerInterceptor) PreProvision(request model.ProvisionRequest) (*mode
 		}
 	}
 	if matched == 0 || unmatched != 0 {
-		return nil, model.HTTPError{ErrorMsg: "InvalidConsumerNetworkProfile", Description: "NetworkProfile was not found or is invalid", StatusCode: http.StatusBadRequest}
+		return model.HTTPError{ErrorMsg: "InvalidConsumerNetworkProfile", Description: "NetworkProfile was not found or is invalid", StatusCode: http.StatusBadRequest}
 	}
-	request.NetworkProfiles = make([]model.NetworkProfile,0)
-	return &request, nil
+	return nil
 }
 
 //PostProvision see interface definition
 func (c ProducerInterceptor) PostProvision(request model.ProvisionRequest, response model.ProvisionResponse) (*model.ProvisionResponse, error) {
-	if len(response.NetworkProfiles) != 0 {
-		networkProfiles, _ := json.Marshal(response.NetworkProfiles)
-		return nil, model.HTTPError{ErrorMsg: "InvalidServerNetworkProfile", Description: "Non-empty NetworkProfile returned from server: " + string(networkProfiles), StatusCode: http.StatusInternalServerError}
+	networkProfiles, err := c.addNetworkProfile(response.NetworkProfiles)
+	c.audit(AuditEvent{Event: auditProvision}, err)
+	if err != nil {
+		return nil, err
+	}
+	response.NetworkProfiles = networkProfiles
+	return &response, nil
+}
+
+//PreUpdate see interface definition
+func (c ProducerInterceptor) PreUpdate(request model.UpdateRequest) (*model.UpdateRequest, error) {
+	err := c.checkConsumerNetworkProfiles(request.NetworkProfiles)
+	if err != nil {
+		return nil, err
+	}
+	request.NetworkProfiles = make([]model.NetworkProfile, 0)
+	return &request, nil
+}
+
+//PostUpdate see interface definition
+func (c ProducerInterceptor) PostUpdate(request model.UpdateRequest, response model.UpdateResponse) (*model.UpdateResponse, error) {
+	networkProfiles, err := c.addNetworkProfile(response.NetworkProfiles)
+	if err != nil {
+		return nil, err
+	}
+	response.NetworkProfiles = networkProfiles
+	return &response, nil
+}
+
+//PostLastOperation see interface definition
+func (c ProducerInterceptor) PostLastOperation(response model.LastOperationResponse) (*model.LastOperationResponse, error) {
+	if response.State != model.OperationSucceeded {
+		return &response, nil
+	}
+	networkProfiles, err := c.addNetworkProfile(response.NetworkProfiles)
+	if err != nil {
+		return nil, err
 	}
-	response.NetworkProfiles = []model.NetworkProfile{{ID: c.NetworkProfile}}
+	response.NetworkProfiles = networkProfiles
 	return &response, nil
 }
 
+func (c ProducerInterceptor) addNetworkProfile(profiles []model.NetworkProfile) ([]model.NetworkProfile, error) {
+	if len(profiles) != 0 {
+		networkProfiles, _ := json.Marshal(profiles)
+		return nil, model.HTTPError{ErrorMsg: "InvalidServerNetworkProfile", Description: "Non-empty NetworkProfile returned from server: " + string(networkProfiles), StatusCode: http.StatusInternalServerError}
+	}
+	return []model.NetworkProfile{{ID: c.NetworkProfile}}, nil
+}
+
 var _ ServiceBrokerInterceptor = &ProducerInterceptor{}
 
 //WriteIstioConfigFiles creates istio config for control plane route
 func (c *ProducerInterceptor) WriteIstioConfigFiles(port int) error {
-	return c.ConfigStore.CreateIstioConfig("istio-broker",
+	return c.ConfigStore.ApplyIstioConfig("", producerBindingID,
 		config.CreateEntriesForExternalService("istio-broker", string(c.IPAddress), uint32(port), "istio-broker."+c.SystemDomain, "", 9000, c.ProviderID))
 }
 
@@ -68,24 +140,48 @@ func (c ProducerInterceptor) PreBind(request model.BindRequest) (*model.BindRequ
 }
 
 //PostBind see interface definition
-func (c ProducerInterceptor) PostBind(request model.BindRequest, response model.BindResponse, bindingID string,
+func (c ProducerInterceptor) PostBind(request model.BindRequest, response model.BindResponse, instanceID string, bindingID string,
 	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error) {
-	systemDomain := c.SystemDomain
-	providerID := c.ProviderID
-	if len(response.Endpoints) == 0 {
-		response.Endpoints = response.Credentials.Endpoints
-	}
-	response.Credentials.Endpoints = nil
-	profiles.AddIstioNetworkDataToResponse(providerID, bindingID, systemDomain, c.LoadBalancerPort, &response, c.NetworkProfile)
+	c.addNetworkData(&response, bindingID)
 
-	err := c.writeIstioFilesForProvider(bindingID, &request, &response)
+	istioObjects, err := c.writeIstioFilesForProvider(instanceID, bindingID, &request, &response)
+	c.audit(AuditEvent{Event: auditBind, InstanceID: instanceID, BindingID: bindingID, ConsumerID: request.NetworkData.Data.ConsumerID, IstioObjects: istioObjects}, err)
 	if err != nil {
-		c.PostUnbind(bindingID)
+		// a conflict means the config belongs to an existing binding which must stay intact
+		if !isConflict(err) {
+			c.deleteIstioConfig(bindingID)
+		}
 		return nil, err
 	}
 	return &response, nil
 }
 
+//PostAsyncBind see interface definition
+func (c ProducerInterceptor) PostAsyncBind(request model.BindRequest, instanceID string, bindingID string) error {
+	return c.ConfigStore.StoreBindRequest(instanceID, bindingID, request)
+}
+
+//PostFetchBinding see interface definition
+func (c ProducerInterceptor) PostFetchBinding(response model.BindResponse, instanceID string, bindingID string,
+	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error) {
+	return postFetchBinding(c.logger, c.ConfigStore, bindingID,
+		func(request model.BindRequest) (*model.BindResponse, error) {
+			return c.PostBind(request, response, instanceID, bindingID, adapt)
+		},
+		func() (*model.BindResponse, error) {
+			c.addNetworkData(&response, bindingID)
+			return &response, nil
+		})
+}
+
+func (c ProducerInterceptor) addNetworkData(response *model.BindResponse, bindingID string) {
+	if len(response.Endpoints) == 0 {
+		response.Endpoints = response.Credentials.Endpoints
+	}
+	response.Credentials.Endpoints = nil
+	profiles.AddIstioNetworkDataToResponse(c.ProviderID, bindingID, c.SystemDomain, c.LoadBalancerPort, response, c.NetworkProfile)
+}
+
 //HasAdaptCredentials see interface definition
 func (c ProducerInterceptor) HasAdaptCredentials() bool {
 	return true
@@ -93,14 +189,53 @@ func (c ProducerInterceptor) HasAdaptCredentials() bool {
 
 //PostUnbind see interface definition
 func (c ProducerInterceptor) PostUnbind(bindID string) {
+	err := c.deleteIstioConfig(bindID)
+	c.audit(AuditEvent{Event: auditUnbind, BindingID: bindID}, err)
+	err = c.ConfigStore.DeleteBindRequest(bindID)
+	if err != nil {
+		c.logger.Warnf("Ignoring error during removal of bind request %s: %v\n", bindID, err)
+	}
+}
+
+//PostAsyncDeprovision see interface definition
+func (c ProducerInterceptor) PostAsyncDeprovision(instanceID string) error {
+	return c.ConfigStore.StoreDeprovision(instanceID)
+}
+
+//PostLastOperationSucceeded see interface definition
+func (c ProducerInterceptor) PostLastOperationSucceeded(instanceID string) bool {
+	return postLastOperationSucceeded(c.logger, c.ConfigStore, instanceID, c.PostDeprovision)
+}
+
+//PostDeprovision see interface definition
+func (c ProducerInterceptor) PostDeprovision(instanceID string) {
+	err := c.ConfigStore.DeleteInstance(instanceID)
+	if err != nil {
+		c.logger.Warnf("Ignoring error during removal of instance-id %s: %v\n", instanceID, err)
+	}
+}
+
+func (c ProducerInterceptor) deleteIstioConfig(bindID string) error {
 	err := c.ConfigStore.DeleteBinding(bindID)
 	if err != nil {
-		log.Warnf("Ignoring error during removal of binding-id %s: %v\n", bindID, err)
+		c.logger.Warnf("Ignoring error during removal of binding-id %s: %v\n", bindID, err)
+	}
+	return err
+}
+
+func (c ProducerInterceptor) writeIstioFilesForProvider(instanceID string, bindingID string, request *model.BindRequest, response *model.BindResponse) ([]string, error) {
+	configurations := config.CreateIstioConfigForProvider(request, response, bindingID, c.SystemDomain, c.ProviderID)
+	err := c.ConfigStore.ApplyIstioConfig(instanceID, bindingID, configurations)
+	if err != nil {
+		return nil, err
 	}
+	return istioObjectNames(configurations), nil
 }
 
-func (c ProducerInterceptor) writeIstioFilesForProvider(bindingID string, request *model.BindRequest, response *model.BindResponse) error {
-	return c.ConfigStore.CreateIstioConfig(bindingID, config.CreateIstioConfigForProvider(request, response, bindingID, c.SystemDomain, c.ProviderID))
+func (c ProducerInterceptor) audit(event AuditEvent, err error) {
+	event.ProviderID = c.ProviderID
+	event.NetworkProfile = c.NetworkProfile
+	recordAudit(c.ctx, c.AuditLog, event, err)
 }
 
 //PostCatalog see interface definition
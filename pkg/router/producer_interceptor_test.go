@@ -84,7 +84,7 @@ func TestEndpointsAreTransferedFromCredentials(t *testing.T) {
 		Credentials: model.Credentials{
 			Endpoints: endpoints,
 		},
-	}, "123", adapt)
+	}, "instance-id", "123", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 	g.Expect(bindResponse.Endpoints).To(Equal(endpoints))
 	g.Expect(len(bindResponse.Credentials.Endpoints)).To(Equal(0))
@@ -106,7 +106,7 @@ func TestConfigFilesAreWrittenAndDeleted(t *testing.T) {
 		Credentials: model.Credentials{
 			Endpoints: endpoints,
 		},
-	}, "123", adapt)
+	}, "instance-id", "123", adapt)
 	g.Expect(err).NotTo(HaveOccurred())
 	fileName := path.Join(configStore.istioDirectory, "123.yml")
 	file, err := os.Open(fileName)
@@ -146,7 +146,7 @@ func TestConfigFilesBindFailsButFileIsCleanedUp(t *testing.T) {
 		Credentials: model.Credentials{
 			Endpoints: endpoints,
 		},
-	}, "cant_be_accessed", adapt)
+	}, "instance-id", "cant_be_accessed", adapt)
 	g.Expect(err).To(HaveOccurred())
 
 	//file should be deleted
@@ -305,4 +305,161 @@ func TestPostProvisionInvalidNetworkProfile(t *testing.T) {
 	g.Expect(httpError.ErrorMsg).To(Equal("InvalidServerNetworkProfile"))
 	g.Expect(httpError.Description).To(ContainSubstring("123"))
 
-}
\ No newline at end of file
+}
+
+func TestPreUpdateValidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{
+		ProviderID:     "your-provider",
+		SystemDomain:   "services.domain",
+		NetworkProfile: "test",
+	}
+	request := model.UpdateRequest{
+		NetworkProfiles: []model.NetworkProfile{{ID: "test"}},
+	}
+	updateRequest, err := interceptor.PreUpdate(request)
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(updateRequest.NetworkProfiles).To(BeEmpty())
+}
+
+func TestPreUpdateWithNoMatchingNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{
+		ProviderID:     "your-provider",
+		SystemDomain:   "services.domain",
+		NetworkProfile: "test",
+	}
+	request := model.UpdateRequest{
+		NetworkProfiles: []model.NetworkProfile{{ID: "test"}, {ID: "123"}},
+	}
+	_, err := interceptor.PreUpdate(request)
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.StatusCode).To(Equal(http.StatusBadRequest))
+	g.Expect(httpError.ErrorMsg).To(Equal("InvalidConsumerNetworkProfile"))
+}
+
+func TestPostUpdate(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{
+		ProviderID:     "your-provider",
+		SystemDomain:   "services.domain",
+		NetworkProfile: "test.xxx",
+	}
+	updateResponse, err := interceptor.PostUpdate(model.UpdateRequest{}, model.UpdateResponse{})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(updateResponse.NetworkProfiles).To(ConsistOf(model.NetworkProfile{ID: "test.xxx"}))
+}
+
+func TestPostUpdateInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{
+		ProviderID:   "your-provider",
+		SystemDomain: "services.domain",
+	}
+	response := model.UpdateResponse{
+		NetworkProfiles: []model.NetworkProfile{{ID: "123"}},
+	}
+	_, err := interceptor.PostUpdate(model.UpdateRequest{}, response)
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.StatusCode).To(Equal(http.StatusInternalServerError))
+	g.Expect(httpError.ErrorMsg).To(Equal("InvalidServerNetworkProfile"))
+	g.Expect(httpError.Description).To(ContainSubstring("123"))
+}
+
+func TestPostLastOperationAddsNetworkProfileOnSuccess(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{NetworkProfile: "test.xxx"}
+
+	lastOperation, err := interceptor.PostLastOperation(model.LastOperationResponse{State: model.OperationSucceeded})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lastOperation.NetworkProfiles).To(ConsistOf(model.NetworkProfile{ID: "test.xxx"}))
+}
+
+func TestPostLastOperationInProgressIsUnchanged(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{NetworkProfile: "test.xxx"}
+
+	lastOperation, err := interceptor.PostLastOperation(model.LastOperationResponse{State: model.OperationInProgress})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lastOperation.NetworkProfiles).To(BeEmpty())
+}
+
+func TestPostLastOperationInvalidNetworkProfile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	interceptor := ProducerInterceptor{NetworkProfile: "test.xxx"}
+
+	_, err := interceptor.PostLastOperation(model.LastOperationResponse{State: model.OperationSucceeded,
+		NetworkProfiles: []model.NetworkProfile{{ID: "123"}}})
+
+	g.Expect(err).To(HaveOccurred())
+	httpError := model.HTTPErrorFromError(err, 0)
+	g.Expect(httpError.StatusCode).To(Equal(http.StatusInternalServerError))
+	g.Expect(httpError.ErrorMsg).To(Equal("InvalidServerNetworkProfile"))
+}
+
+func TestAsyncBindWritesConfigOnFetch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := &fileConfigStore{istioDirectory: os.TempDir()}
+	interceptor := ProducerInterceptor{
+		ProviderID:       "cf-service.istio.my.arbitrary.domain.io",
+		SystemDomain:     "istio.my.arbitrary.domain.io",
+		LoadBalancerPort: 9000,
+		ConfigStore:      configStore,
+		NetworkProfile:   "urn:local.test:public",
+	}
+	request := model.BindRequest{NetworkData: model.NetworkDataRequest{Data: model.DataRequest{ConsumerID: "async-consumer"}}}
+	err := interceptor.PostAsyncBind(request, "instance-id", "async-123")
+	g.Expect(err).NotTo(HaveOccurred())
+	fileName := path.Join(configStore.istioDirectory, "async-123.yml")
+	_, err = os.Stat(fileName)
+	g.Expect(err).To(HaveOccurred())
+
+	binding, err := interceptor.PostFetchBinding(model.BindResponse{
+		Credentials: model.Credentials{
+			Endpoints: []model.Endpoint{{Host: "test.local", Port: 5757}},
+		},
+	}, "instance-id", "async-123", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(binding.NetworkData.NetworkProfileID).To(Equal("urn:local.test:public"))
+	content, err := ioutil.ReadFile(fileName)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(content)).To(ContainSubstring("async-consumer"))
+
+	pending, err := configStore.LoadBindRequest("async-123")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeNil())
+
+	interceptor.PostUnbind("async-123")
+}
+
+func TestFetchBindingAddsNetworkDataWithoutWritingConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	configStore := &MockConfigStore{}
+	interceptor := ProducerInterceptor{
+		ProviderID:       "your-provider",
+		SystemDomain:     "services.domain",
+		LoadBalancerPort: 9000,
+		ConfigStore:      configStore,
+		NetworkProfile:   "urn:local.test:public",
+	}
+
+	binding, err := interceptor.PostFetchBinding(model.BindResponse{
+		Credentials: model.Credentials{
+			Endpoints: []model.Endpoint{{Host: "test.local", Port: 5757}},
+		},
+	}, "instance-id", "123", adapt)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(configStore.CreatedIstioConfigs).To(BeEmpty())
+	g.Expect(binding.Endpoints).To(ConsistOf(model.Endpoint{Host: "test.local", Port: 5757}))
+	g.Expect(binding.Credentials.Endpoints).To(BeNil())
+	g.Expect(binding.NetworkData.Data.ProviderID).To(Equal("your-provider"))
+	g.Expect(binding.NetworkData.Data.Endpoints).To(ConsistOf(model.Endpoint{Host: "0.123.services.domain", Port: 9000}))
+}
@@ -0,0 +1,155 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	readyEndpoint    = "/ready"
+	readinessTimeout = 5 * time.Second
+	statusUp         = "UP"
+	statusDown       = "DOWN"
+)
+
+//ReadinessCheck verifies a dependency which has to be available before the proxy accepts requests
+type ReadinessCheck interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c readinessCheck) Name() string {
+	return c.name
+}
+
+func (c readinessCheck) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+//NewConfigStoreReadinessCheck creates a ReadinessCheck calling the Health method of the given ConfigStore
+func NewConfigStoreReadinessCheck(configStore ConfigStore) ReadinessCheck {
+	return readinessCheck{"configStore", func(ctx context.Context) error {
+		return configStore.Health()
+	}}
+}
+
+// newUpstreamReadinessCheck requests the catalog of the broker, any response except 5xx shows that it is reachable
+func newUpstreamReadinessCheck(name string, proxy *osbProxy) ReadinessCheck {
+	return readinessCheck{name, func(ctx context.Context) error {
+		version := model.BrokerAPIVersion{Major: 2, Minor: 14}
+		if proxy.config.MinBrokerAPIVersion != nil {
+			version = *proxy.config.MinBrokerAPIVersion
+		}
+		header := http.Header{}
+		header.Set(BrokerAPIVersionHeader, version.String())
+		header, err := upstreamHeader(proxy.config.UpstreamCredentials, header, nil)
+		if err != nil {
+			return err
+		}
+		request, err := proxy.config.HTTPRequestFactory(http.MethodGet, proxy.config.ForwardURL+"/v2/catalog", header, http.NoBody)
+		if err != nil {
+			return err
+		}
+		response, err := proxy.Do(request.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode >= 500 {
+			return fmt.Errorf("catalog request returned %s", response.Status)
+		}
+		return nil
+	}}
+}
+
+// configStoreOf returns the ConfigStore of the interceptors of this package
+func configStoreOf(interceptor ServiceBrokerInterceptor) ConfigStore {
+	switch t := interceptor.(type) {
+	case *ConsumerInterceptor:
+		return t.ConfigStore
+	case *ProducerInterceptor:
+		return t.ConfigStore
+	case ConsumerInterceptor:
+		return t.ConfigStore
+	case ProducerInterceptor:
+		return t.ConfigStore
+	}
+	return nil
+}
+
+// readinessChecks contains the additional checks of the config, the shutdown, the ConfigStore of the interceptor and the brokers
+func readinessChecks(interceptor ServiceBrokerInterceptor, routerConfig Config, proxies *brokerProxies) []ReadinessCheck {
+	checks := append([]ReadinessCheck{}, routerConfig.ReadinessChecks...)
+	if routerConfig.Lifecycle != nil {
+		checks = append(checks, routerConfig.Lifecycle)
+	}
+	if configStore := configStoreOf(interceptor); configStore != nil {
+		checks = append(checks, NewConfigStoreReadinessCheck(configStore))
+	}
+	if proxies.defaultProxy.config.ForwardURL != "" {
+		checks = append(checks, newUpstreamReadinessCheck("upstream/"+defaultBrokerID, proxies.defaultProxy))
+	}
+	brokerIDs := make([]string, 0, len(proxies.brokers))
+	for brokerID := range proxies.brokers {
+		brokerIDs = append(brokerIDs, brokerID)
+	}
+	sort.Strings(brokerIDs)
+	for _, brokerID := range brokerIDs {
+		checks = append(checks, newUpstreamReadinessCheck("upstream/"+brokerID, proxies.brokers[brokerID]))
+	}
+	return checks
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ready runs all checks in parallel and responds with 503 if one of them fails
+func ready(checks []ReadinessCheck) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		checkContext, cancel := context.WithTimeout(ctx.Request.Context(), readinessTimeout)
+		defer cancel()
+		results := make(map[string]checkResult)
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			wg.Add(1)
+			go func(check ReadinessCheck) {
+				defer wg.Done()
+				result := checkResult{Status: statusUp}
+				if err := check.Check(checkContext); err != nil {
+					requestLog(ctx.Request.Context()).Warnf("Readiness check %s failed: %v\n", check.Name(), err)
+					result = checkResult{Status: statusDown, Error: model.Redact(err.Error())}
+				}
+				mutex.Lock()
+				results[check.Name()] = result
+				mutex.Unlock()
+			}(check)
+		}
+		wg.Wait()
+		status, code := statusUp, http.StatusOK
+		for _, result := range results {
+			if result.Status != statusUp {
+				status, code = statusDown, http.StatusServiceUnavailable
+			}
+		}
+		ctx.JSON(code, gin.H{"status": status, "checks": results})
+	}
+}
+
+// isProbe checks for the endpoints of the kubernetes probes, which are neither authenticated nor traced
+func isProbe(path string) bool {
+	return path == healthEnpoint || path == readyEndpoint
+}
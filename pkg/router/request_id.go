@@ -0,0 +1,100 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gin-gonic/gin"
+	"istio.io/istio/pkg/log"
+	"regexp"
+)
+
+const (
+	// RequestIDHeader identifies a request across the proxies and brokers it passes
+	RequestIDHeader = "X-Request-ID"
+	// CorrelationIDHeader is accepted as alternative to the RequestIDHeader
+	CorrelationIDHeader = "X-Correlation-ID"
+)
+
+type requestIDKey struct{}
+
+// ids of callers are written to the log, so anything which could forge log lines is replaced
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func assignRequestID(ctx *gin.Context) {
+	header := ctx.Request.Header
+	requestID := header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(requestID) {
+		requestID = header.Get(CorrelationIDHeader)
+	}
+	if !validRequestID.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	correlationID := header.Get(CorrelationIDHeader)
+	if !validRequestID.MatchString(correlationID) {
+		correlationID = requestID
+	}
+	header.Set(RequestIDHeader, requestID)
+	header.Set(CorrelationIDHeader, correlationID)
+	ctx.Header(RequestIDHeader, requestID)
+	ctx.Header(CorrelationIDHeader, correlationID)
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), requestIDKey{}, requestID))
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+func requestIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+type requestLogger struct {
+	prefix string
+}
+
+// requestLog prefixes each log line with the id of the request in the context and redacts credentials
+func requestLog(ctx context.Context) requestLogger {
+	return newRequestLogger(requestIDFrom(ctx))
+}
+
+func newRequestLogger(requestID string) requestLogger {
+	if requestID == "" {
+		return requestLogger{}
+	}
+	return requestLogger{"[" + requestID + "] "}
+}
+
+func (l requestLogger) Debugf(template string, args ...interface{}) {
+	if log.DebugEnabled() {
+		log.Debug(l.format(template, args))
+	}
+}
+
+func (l requestLogger) Infof(template string, args ...interface{}) {
+	log.Info(l.format(template, args))
+}
+
+func (l requestLogger) Warnf(template string, args ...interface{}) {
+	log.Warn(l.format(template, args))
+}
+
+func (l requestLogger) Errorf(template string, args ...interface{}) {
+	log.Error(l.format(template, args))
+}
+
+// format masks credentials, as the arguments often contain errors or responses of the broker
+func (l requestLogger) format(template string, args []interface{}) string {
+	return l.prefix + model.Redact(fmt.Sprintf(template, args...))
+}
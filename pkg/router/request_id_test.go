@@ -0,0 +1,67 @@
+package router
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func assignedRequestID(header http.Header) (*gin.Context, *httptest.ResponseRecorder) {
+	response := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(response)
+	ctx.Request, _ = http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", nil)
+	for name := range header {
+		ctx.Request.Header.Set(name, header[name][0])
+	}
+	assignRequestID(ctx)
+	return ctx, response
+}
+
+func TestRequestIDIsAccepted(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx, response := assignedRequestID(http.Header{RequestIDHeader: {"req-1"}, CorrelationIDHeader: {"corr-1"}})
+
+	g.Expect(requestIDFrom(ctx.Request.Context())).To(Equal("req-1"))
+	g.Expect(response.Header().Get(RequestIDHeader)).To(Equal("req-1"))
+	g.Expect(response.Header().Get(CorrelationIDHeader)).To(Equal("corr-1"))
+	g.Expect(ctx.Request.Header.Get(CorrelationIDHeader)).To(Equal("corr-1"))
+}
+
+func TestCorrelationIDIsUsedAsRequestID(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx, _ := assignedRequestID(http.Header{CorrelationIDHeader: {"corr-1"}})
+
+	g.Expect(requestIDFrom(ctx.Request.Context())).To(Equal("corr-1"))
+	g.Expect(ctx.Request.Header.Get(RequestIDHeader)).To(Equal("corr-1"))
+}
+
+func TestInvalidRequestIDIsReplaced(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx, response := assignedRequestID(http.Header{RequestIDHeader: {"forged\nlog line"}})
+
+	requestID := requestIDFrom(ctx.Request.Context())
+	g.Expect(requestID).To(MatchRegexp("^[0-9a-f]{32}$"))
+	g.Expect(response.Header().Get(RequestIDHeader)).To(Equal(requestID))
+	g.Expect(response.Header().Get(CorrelationIDHeader)).To(Equal(requestID))
+}
+
+func TestRequestLogPrefix(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(requestLog(context.Background()).prefix).To(BeEmpty())
+	g.Expect(requestLog(context.WithValue(context.Background(), requestIDKey{}, "req-1")).prefix).To(Equal("[req-1] "))
+}
+
+func TestConfigStoreLogsWithRequestID(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	instrumented := &instrumentedConfigStore{kubeConfigStore{namespace: "catalog"}}
+
+	traced := newTracedConfigStore(ctx, instrumented).(*tracedConfigStore)
+
+	kube := traced.delegate.(*instrumentedConfigStore).delegate.(kubeConfigStore)
+	g.Expect(kube.logger.prefix).To(Equal("[req-1] "))
+	g.Expect(instrumented.delegate.(kubeConfigStore).logger.prefix).To(BeEmpty())
+}
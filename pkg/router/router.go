@@ -1,13 +1,17 @@
 package router
 
 import (
-	"crypto/tls"
+	"bytes"
 	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/api"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/Peripli/istio-broker-proxy/pkg/tracing"
 	"github.com/gin-gonic/gin"
 	"io"
-	"istio.io/istio/pkg/log"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -15,8 +19,11 @@ const (
 	DefaultPort        = 8080
 	// IstioBrokerVersion is a header entry that contains the commit-shas of istio-broker-proxy
 	IstioBrokerVersion = "X-Istio-Broker-Versions"
+	// BrokerAPIVersionHeader is the header entry which contains the version of the open service broker API
+	BrokerAPIVersionHeader = "X-Broker-API-Version"
 
 	healthEnpoint = "/health"
+	brokerAPIVersionKey = "istio-broker-proxy-broker-api-version"
 )
 
 var version string
@@ -25,15 +32,59 @@ var version string
 type Config struct {
 	ForwardURL         string
 	SkipVerifyTLS      bool
+	// CACertFile contains the CAs to verify the certificate of the ForwardURL instead of the system CAs
+	CACertFile string
+	// ClientCertFile and ClientKeyFile authenticate the proxy at the ForwardURL
+	ClientCertFile string
+	ClientKeyFile  string
+	// ServerName is expected in the certificate of the ForwardURL instead of its host name
+	ServerName string
+	// UpstreamCredentials replace the authorization of incoming requests, nil forwards it unchanged. Brokers of the
+	// registry without own credentials use them as well.
+	UpstreamCredentials UpstreamCredentials
 	Port               int
 	HTTPClientFactory  func(tr *http.Transport) *http.Client
 	HTTPRequestFactory func(method string, url string, header http.Header, body io.Reader) (*http.Request, error)
+	BindingLock        BindingLock
+	// Brokers maps the broker_id of /v1/osb/:broker_id requests to the settings of the respective broker
+	Brokers map[string]BrokerConfig
+	// MinBrokerAPIVersion rejects requests with an older X-Broker-API-Version, nil accepts all requests
+	MinBrokerAPIVersion *model.BrokerAPIVersion
+	// Authenticators of which one has to accept each request except the probes, none disables authentication
+	Authenticators []Authenticator
+	// RequireClientCertificate rejects requests except the probes without a verified TLS client certificate
+	RequireClientCertificate bool
+	// Upstream configures the timeouts and retries of the calls to the ForwardURL
+	Upstream UpstreamPolicy
+	// CircuitBreaker lets calls to an unavailable ForwardURL fail fast with 503
+	CircuitBreaker CircuitBreakerConfig
+	// ReadinessChecks are run by the readiness probe in addition to the ConfigStore and the upstream catalog checks
+	ReadinessChecks []ReadinessCheck
+	// Lifecycle lets binds in flight finish or roll back on shutdown, nil disables the tracking of binds
+	Lifecycle *Lifecycle
 }
 
 type osbProxy struct {
 	*http.Client
 	interceptor ServiceBrokerInterceptor
 	config      Config
+	breaker     *circuitBreaker
+}
+
+func newOsbProxy(interceptor ServiceBrokerInterceptor, routerConfig Config) osbProxy {
+	tlsConfig, err := newUpstreamTLSConfig(routerConfig)
+	if err != nil {
+		panic(fmt.Sprintf("invalid TLS configuration for %s: %v", routerConfig.ForwardURL, err))
+	}
+	tr := &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+	breaker := newCircuitBreaker(routerConfig.CircuitBreaker, routerConfig.ForwardURL)
+	return osbProxy{withCircuitBreaker(routerConfig.HTTPClientFactory(tr), breaker), interceptor, routerConfig, breaker}
+}
+
+func (client osbProxy) newInterceptedOsbClient(request *http.Request) interceptedOsbClient {
+	return interceptedOsbClient{OsbClient: &osbClient{&restClient{client.Client, request, client.config}}, Interceptor: client.interceptor, ctx: request.Context()}
 }
 
 func (client osbProxy) updateCredentials(ctx *gin.Context) {
@@ -57,45 +108,84 @@ func (client osbProxy) forward(ctx *gin.Context) {
 	})
 }
 
+func (client osbProxy) lockBinding(ctx *gin.Context) {
+	bindingID := ctx.Params.ByName("binding_id")
+	locked, err := client.config.BindingLock.TryLock(ctx.Request.Context(), bindingID)
+	if err != nil {
+		httpError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	if !locked {
+		httpError(ctx, model.HTTPError{ErrorMsg: "ConcurrencyError", Description: fmt.Sprintf("Another operation for binding %s is in progress", bindingID), StatusCode: http.StatusUnprocessableEntity}, http.StatusUnprocessableEntity)
+		return
+	}
+	defer func() {
+		err := client.config.BindingLock.Unlock(ctx.Request.Context(), bindingID)
+		if err != nil {
+			requestLog(ctx.Request.Context()).Warnf("Ignoring error during unlock of binding %s: %v\n", bindingID, err)
+		}
+	}()
+	ctx.Next()
+}
+
 func (client osbProxy) forwardUnbindRequest(ctx *gin.Context) {
 	bindingID := ctx.Params.ByName("binding_id")
-	osbClient := interceptedOsbClient{&osbClient{&restClient{client.Client, ctx.Request, client.config}}, client.interceptor}
-	err := osbClient.Unbind(bindingID)
+	osbClient := client.newInterceptedOsbClient(ctx.Request)
+	response, err := osbClient.Unbind(bindingID)
 	if err != nil {
 		httpError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ctx.JSON(http.StatusOK, map[string]string{})
+	writeResponse(ctx, response, map[string]string{})
 }
 
 func (client osbProxy) forwardCatalog(ctx *gin.Context) {
-	osbClient := interceptedOsbClient{&osbClient{&restClient{client.Client, ctx.Request, client.config}}, client.interceptor}
-	catalog, err := osbClient.GetCatalog()
+	osbClient := client.newInterceptedOsbClient(ctx.Request)
+	catalog, response, err := osbClient.GetCatalog()
 	if err != nil {
 		httpError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ctx.JSON(http.StatusOK, catalog)
+	writeResponse(ctx, response, catalog)
 }
 
 func (client osbProxy) forwardWithCallback(ctx *gin.Context, postCallback func(ctx *gin.Context) error) {
 	writer := ctx.Writer
 	request := ctx.Request
 
-	log.Infof("Received request: %v %v", request.Method, request.URL.Path)
+	requestLog(request.Context()).Infof("Received request: %v %v", request.Method, request.URL.Path)
 
 	url := createNewURL(client.config.ForwardURL, request)
-	proxyRequest, err := client.config.HTTPRequestFactory(request.Method, url, request.Header, request.Body)
+	_, span := tracing.StartSpan(request.Context(), "upstream "+request.Method)
+	span.SetAttribute("http.url", url)
+	header, err := upstreamHeader(client.config.UpstreamCredentials, request.Header, span)
 	if err != nil {
+		span.Finish(err)
 		httpError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	response, err := client.Do(proxyRequest)
+	body := func() io.Reader { return request.Body }
+	if client.config.Upstream.retries(operationFrom(request.Context())) > 0 {
+		// each attempt needs its own reader of the body
+		content, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			span.Finish(err)
+			httpError(ctx, err, http.StatusBadRequest)
+			return
+		}
+		body = func() io.Reader { return bytes.NewReader(content) }
+	}
+	response, err := doUpstream(request.Context(), client.Client, client.config.Upstream, func() (*http.Request, error) {
+		return client.config.HTTPRequestFactory(request.Method, url, header, body())
+	})
 	if err != nil {
+		span.Finish(err)
 		httpError(ctx, err, http.StatusBadGateway)
 		return
 	}
-	log.Infof("Request forwarded %v: %s\n", request.URL, response.Status)
+	span.SetAttribute("http.status_code", strconv.Itoa(response.StatusCode))
+	span.Finish(nil)
+	requestLog(request.Context()).Infof("Request forwarded %v: %s\n", request.URL, response.Status)
 
 	defer response.Body.Close()
 
@@ -125,15 +215,33 @@ func (client osbProxy) forwardBindRequest(ctx *gin.Context) {
 		return
 	}
 
-	osbClient := interceptedOsbClient{&osbClient{&restClient{client.Client, request, client.config}}, client.interceptor}
-	log.Infof("Received request: %v %v", request.Method, request.URL.Path)
+	osbClient := client.newInterceptedOsbClient(request)
+	requestLog(request.Context()).Infof("Received request: %v %v", request.Method, request.URL.Path)
+	instanceID := ctx.Params.ByName("instance_id")
+	bindingID := ctx.Params.ByName("binding_id")
+	bindResponse, response, err := osbClient.Bind(instanceID, bindingID, &bindRequest)
+	// a conflict belongs to an existing binding, which must not be rolled back
+	if bindAborted(request.Context()) && (err == nil || !isConflict(err)) {
+		httpError(ctx, client.rollbackBind(request, bindRequest, bindingID, err, osbClient.orphanMitigated), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		httpError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	writeResponse(ctx, response, bindResponse)
+}
+
+func (client osbProxy) forwardFetchBindingRequest(ctx *gin.Context) {
+	instanceID := ctx.Params.ByName("instance_id")
 	bindingID := ctx.Params.ByName("binding_id")
-	bindResponse, err := osbClient.Bind(bindingID, &bindRequest)
+	osbClient := client.newInterceptedOsbClient(ctx.Request)
+	bindResponse, response, err := osbClient.FetchBinding(instanceID, bindingID)
 	if err != nil {
 		httpError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ctx.JSON(http.StatusOK, bindResponse)
+	writeResponse(ctx, response, bindResponse)
 }
 
 func (client osbProxy) forwardProvisionRequest(ctx *gin.Context) {
@@ -146,19 +254,85 @@ func (client osbProxy) forwardProvisionRequest(ctx *gin.Context) {
 		return
 	}
 
-	osbClient := interceptedOsbClient{&osbClient{&restClient{client.Client, request, client.config}}, client.interceptor}
-	log.Infof("Received request: %v %v", request.Method, request.URL.Path)
-	provisionResponse, err := osbClient.Provision(&provisionRequest)
+	osbClient := client.newInterceptedOsbClient(request)
+	requestLog(request.Context()).Infof("Received request: %v %v", request.Method, request.URL.Path)
+	provisionResponse, response, err := osbClient.Provision(&provisionRequest)
+	if err != nil {
+		httpError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	writeResponse(ctx, response, provisionResponse)
+}
+
+func (client osbProxy) forwardUpdateRequest(ctx *gin.Context) {
+	request := ctx.Request
+
+	var updateRequest model.UpdateRequest
+	err := ctx.ShouldBindJSON(&updateRequest)
+	if err != nil {
+		httpError(ctx, err, http.StatusBadRequest)
+		return
+	}
+
+	osbClient := client.newInterceptedOsbClient(request)
+	requestLog(request.Context()).Infof("Received request: %v %v", request.Method, request.URL.Path)
+	updateResponse, response, err := osbClient.Update(&updateRequest)
 	if err != nil {
 		httpError(ctx, err, http.StatusInternalServerError)
 		return
 	}
-	ctx.JSON(http.StatusOK, provisionResponse)
+	writeResponse(ctx, response, updateResponse)
+}
+
+func (client osbProxy) forwardDeprovisionRequest(ctx *gin.Context) {
+	instanceID := ctx.Params.ByName("instance_id")
+	osbClient := client.newInterceptedOsbClient(ctx.Request)
+	deprovisionResponse, response, err := osbClient.Deprovision(instanceID)
+	if err != nil {
+		httpError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	writeResponse(ctx, response, deprovisionResponse)
+}
+
+func (client osbProxy) forwardLastOperationRequest(ctx *gin.Context) {
+	instanceID := ctx.Params.ByName("instance_id")
+	osbClient := client.newInterceptedOsbClient(ctx.Request)
+	lastOperation, response, err := osbClient.GetLastOperation(instanceID)
+	if err != nil {
+		httpError(ctx, err, http.StatusInternalServerError)
+		return
+	}
+	writeResponse(ctx, response, lastOperation)
+}
+
+// writeResponse echoes status code and headers of the upstream response together with the (modified) body
+func writeResponse(ctx *gin.Context, response api.RESTResponse, body interface{}) {
+	for name, values := range response.Header() {
+		if !isBodyHeader(name) {
+			ctx.Writer.Header()[name] = values
+		}
+	}
+	ctx.JSON(response.StatusCode(), body)
+}
+
+// isBodyHeader checks for headers which describe the upstream body and are invalid for the modified body
+func isBodyHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Content-Length", "Content-Type", "Content-Encoding", "Transfer-Encoding":
+		return true
+	}
+	return false
 }
 
 func httpError(ctx *gin.Context, err error, statusCode int) {
-	log.Errorf("ERROR: %s\n", err.Error())
-	httpError := model.HTTPErrorFromError(err, statusCode)
+	requestID := requestIDFrom(ctx.Request.Context())
+	newRequestLogger(requestID).Errorf("ERROR: %s\n", err.Error())
+	// the redacted error is a copy, so predefined errors stay unchanged
+	httpError := model.RedactError(model.HTTPErrorFromError(err, statusCode)).(*model.HTTPError)
+	if requestID != "" {
+		httpError.Description = fmt.Sprintf("%s (request id: %s)", httpError.Description, requestID)
+	}
 	ctx.AbortWithStatusJSON(httpError.StatusCode, httpError)
 }
 
@@ -170,7 +344,7 @@ func httpClientFactory(tr *http.Transport) *http.Client {
 func httpRequestFactory(method string, url string, header http.Header, body io.Reader) (*http.Request, error) {
 	request, err := http.NewRequest(method, url, body)
 	if err != nil {
-		log.Errorf("error during create request: %s\n", err.Error())
+		newRequestLogger(header.Get(RequestIDHeader)).Errorf("error during create request: %s\n", err.Error())
 		return nil, err
 	}
 	if header != nil {
@@ -179,7 +353,7 @@ func httpRequestFactory(method string, url string, header http.Header, body io.R
 		request.Header = http.Header{}
 	}
 	request.Header.Add(IstioBrokerVersion, version)
-	log.Infof("Added header %s with value %s\n", IstioBrokerVersion, version)
+	newRequestLogger(request.Header.Get(RequestIDHeader)).Infof("Added header %s with value %s\n", IstioBrokerVersion, version)
 
 	return request, nil
 }
@@ -198,11 +372,22 @@ func createNewPath(req *http.Request) string {
 	return path
 }
 
-func registerConsumerRelevantRoutes(prefix string, mux *gin.Engine, client *osbProxy) {
-	mux.PUT(prefix+"/v2/service_instances/:instance_id/service_bindings/:binding_id", client.forwardBindRequest)
-	mux.DELETE(prefix+"/v2/service_instances/:instance_id/service_bindings/:binding_id", client.forwardUnbindRequest)
-	mux.PUT(prefix+"/v2/service_instances/:instance_id", client.forwardProvisionRequest)
-	mux.GET(prefix+"/v2/catalog", client.forwardCatalog)
+func registerConsumerRelevantRoutes(prefix string, mux *gin.Engine, proxies *brokerProxies, middleware ...gin.HandlerFunc) {
+	route := func(operation string, handlers ...func(osbProxy, *gin.Context)) []gin.HandlerFunc {
+		chain := append([]gin.HandlerFunc{observeOperation(operation, proxies), setOperation(operation)}, middleware...)
+		for _, handler := range handlers {
+			chain = append(chain, proxies.handle(handler))
+		}
+		return chain
+	}
+	mux.PUT(prefix+"/v2/service_instances/:instance_id/service_bindings/:binding_id", route("bind", osbProxy.trackBind, osbProxy.lockBinding, osbProxy.forwardBindRequest)...)
+	mux.DELETE(prefix+"/v2/service_instances/:instance_id/service_bindings/:binding_id", route("unbind", osbProxy.lockBinding, osbProxy.forwardUnbindRequest)...)
+	mux.GET(prefix+"/v2/service_instances/:instance_id/service_bindings/:binding_id", route("fetch_binding", osbProxy.lockBinding, osbProxy.forwardFetchBindingRequest)...)
+	mux.PUT(prefix+"/v2/service_instances/:instance_id", route("provision", osbProxy.forwardProvisionRequest)...)
+	mux.PATCH(prefix+"/v2/service_instances/:instance_id", route("update", osbProxy.forwardUpdateRequest)...)
+	mux.DELETE(prefix+"/v2/service_instances/:instance_id", route("deprovision", osbProxy.forwardDeprovisionRequest)...)
+	mux.GET(prefix+"/v2/service_instances/:instance_id/last_operation", route("last_operation", osbProxy.forwardLastOperationRequest)...)
+	mux.GET(prefix+"/v2/catalog", route("catalog", osbProxy.forwardCatalog)...)
 }
 
 func logAndAddVersionHeader(ctx *gin.Context) {
@@ -210,11 +395,85 @@ func logAndAddVersionHeader(ctx *gin.Context) {
 	istioBrokerVersion := ctx.Request.Header.Get(IstioBrokerVersion)
 	ctx.Next()
 	ctx.Header(IstioBrokerVersion, version)
-	if path != healthEnpoint {
-		log.Infof("Header %s:  received \"%s\", responded \"%s\"\n",IstioBrokerVersion, istioBrokerVersion, version)
+	if !isProbe(path) {
+		requestLog(ctx.Request.Context()).Infof("Header %s:  received \"%s\", responded \"%s\"\n",IstioBrokerVersion, istioBrokerVersion, version)
 	}
 }
 
+func authenticate(authenticators []Authenticator) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if len(authenticators) == 0 || isProbe(ctx.Request.URL.Path) {
+			return
+		}
+		var lastErr error
+		for _, authenticator := range authenticators {
+			ok, err := authenticator.Authenticate(ctx.Request)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if ok {
+				return
+			}
+		}
+		if lastErr != nil {
+			httpError(ctx, fmt.Errorf("unable to authenticate request: %v", lastErr), http.StatusInternalServerError)
+			return
+		}
+		ctx.Header("WWW-Authenticate", `Basic realm="istio-broker-proxy"`)
+		httpError(ctx, model.HTTPError{ErrorMsg: "Unauthorized", Description: "Missing or invalid credentials", StatusCode: http.StatusUnauthorized}, http.StatusUnauthorized)
+	}
+}
+
+func traceRequest(ctx *gin.Context) {
+	path := ctx.Request.URL.Path
+	if isProbe(path) || path == metricsEndpoint {
+		return
+	}
+	requestContext, span := tracing.StartSpanFromHeader(ctx.Request.Context(), ctx.Request.Method+" "+path, ctx.Request.Header)
+	ctx.Request = ctx.Request.WithContext(requestContext)
+	ctx.Next()
+	span.SetAttribute("http.status_code", strconv.Itoa(ctx.Writer.Status()))
+	if broker := ctx.Params.ByName("broker_id"); broker != "" {
+		span.SetAttribute("broker", broker)
+	}
+	span.Finish(nil)
+}
+
+func requireClientCertificate(ctx *gin.Context) {
+	if isProbe(ctx.Request.URL.Path) {
+		return
+	}
+	if ctx.Request.TLS == nil || len(ctx.Request.TLS.VerifiedChains) == 0 {
+		httpError(ctx, model.HTTPError{ErrorMsg: "Forbidden", Description: "A verified client certificate is required", StatusCode: http.StatusForbidden}, http.StatusForbidden)
+	}
+}
+
+func checkBrokerAPIVersion(minimum *model.BrokerAPIVersion) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.Request.Header.Get(BrokerAPIVersionHeader)
+		version, err := model.ParseBrokerAPIVersion(header)
+		if err == nil {
+			ctx.Set(brokerAPIVersionKey, version)
+		}
+		if minimum == nil || !isOsbRequest(ctx.Request.URL.Path) {
+			return
+		}
+		if err != nil {
+			httpError(ctx, model.HTTPError{ErrorMsg: "PreconditionFailed", Description: fmt.Sprintf("%s %q is missing or invalid, required is %s", BrokerAPIVersionHeader, header, minimum), StatusCode: http.StatusPreconditionFailed}, http.StatusPreconditionFailed)
+			return
+		}
+		if !version.Supports(*minimum) {
+			httpError(ctx, model.HTTPError{ErrorMsg: "PreconditionFailed", Description: fmt.Sprintf("%s %s is not supported, required is %s", BrokerAPIVersionHeader, version, minimum), StatusCode: http.StatusPreconditionFailed}, http.StatusPreconditionFailed)
+		}
+	}
+}
+
+// the probes, the metrics and the adapt_credentials call of the consumer are no open service broker API calls
+func isOsbRequest(path string) bool {
+	return !isProbe(path) && path != metricsEndpoint && !strings.HasSuffix(path, "/adapt_credentials")
+}
+
 //SetupRouter creates the istio-broker-proxy's endpoints
 func SetupRouter(interceptor ServiceBrokerInterceptor, routerConfig Config) *gin.Engine {
 	return SetupRouterWithVersion(interceptor,routerConfig,"")
@@ -231,23 +490,29 @@ func SetupRouterWithVersion(interceptor ServiceBrokerInterceptor, routerConfig C
 	if routerConfig.Port == 0 {
 		routerConfig.Port = DefaultPort
 	}
+	if routerConfig.BindingLock == nil {
+		routerConfig.BindingLock = NewInProcessBindingLock()
+	}
 
 	mux := gin.New()
-	mux.Use(gin.LoggerWithWriter(gin.DefaultWriter, healthEnpoint), gin.Recovery())
-	mux.Use(logAndAddVersionHeader)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: routerConfig.SkipVerifyTLS},
+	mux.Use(gin.LoggerWithWriter(gin.DefaultWriter, healthEnpoint, readyEndpoint), gin.Recovery(), assignRequestID)
+	if routerConfig.RequireClientCertificate {
+		mux.Use(requireClientCertificate)
 	}
-	client := osbProxy{routerConfig.HTTPClientFactory(tr), interceptor, routerConfig}
+	mux.Use(traceRequest, logAndAddVersionHeader, authenticate(routerConfig.Authenticators), checkBrokerAPIVersion(routerConfig.MinBrokerAPIVersion))
+	client := newOsbProxy(interceptor, routerConfig)
+	proxies := newBrokerProxies(&client, interceptor, routerConfig)
 	mux.GET(healthEnpoint, func(ctx *gin.Context) {
-		ctx.Status(http.StatusOK)
+		ctx.JSON(http.StatusOK, gin.H{"status": "UP", "circuitBreakers": proxies.circuitBreakerStates()})
 	})
+	mux.GET(readyEndpoint, ready(readinessChecks(interceptor, routerConfig, proxies)))
+	mux.GET(metricsEndpoint, metricsHandler())
 	if interceptor.HasAdaptCredentials() {
 		mux.POST("/v2/service_instances/:instance_id/service_bindings/:binding_id/adapt_credentials", client.updateCredentials)
 	}
-	registerConsumerRelevantRoutes("", mux, &client)
-	registerConsumerRelevantRoutes("/v1/osb/:broker_id", mux, &client)
-	mux.NoRoute(client.forward)
+	registerConsumerRelevantRoutes("", mux, proxies)
+	registerConsumerRelevantRoutes(brokerPathPrefix+":broker_id", mux, proxies, proxies.selectBroker)
+	mux.NoRoute(proxies.forward)
 
 	return mux
 }
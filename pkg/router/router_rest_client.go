@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"github.com/Peripli/istio-broker-proxy/pkg/api"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/Peripli/istio-broker-proxy/pkg/tracing"
 	"io/ioutil"
-	"istio.io/istio/pkg/log"
 	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 type restClient struct {
@@ -27,9 +30,12 @@ type restRequest struct {
 }
 
 type restResponse struct {
-	err      error
-	response []byte
-	url      string
+	err        error
+	response   []byte
+	url        string
+	statusCode int
+	header     http.Header
+	logger     requestLogger
 }
 
 func (client *restClient) Get() api.RESTRequest {
@@ -50,6 +56,11 @@ func (client *restClient) Put(request interface{}) api.RESTRequest {
 	return client.createRequest(http.MethodPut, requestBody, err)
 }
 
+func (client *restClient) Patch(request interface{}) api.RESTRequest {
+	requestBody, err := json.Marshal(request)
+	return client.createRequest(http.MethodPatch, requestBody, err)
+}
+
 func (client *restClient) createRequest(method string, body []byte, err error) *restRequest {
 	return &restRequest{method: method, client: client, request: body, url: createNewURL(client.config.ForwardURL, client.request)}
 }
@@ -60,31 +71,49 @@ func (o *restRequest) AppendPath(path string) api.RESTRequest {
 	return o
 }
 
+func (o *restRequest) SetQuery(query url.Values) api.RESTRequest {
+	o.url = strings.SplitN(o.url, "?", 2)[0] + "?" + query.Encode()
+	return o
+}
+
 func (o *restRequest) Do() api.RESTResponse {
-	osbResponse := restResponse{err: o.err, url: o.url}
+	osbResponse := restResponse{err: o.err, url: o.url, logger: requestLog(o.client.request.Context())}
 	if o.err != nil {
 		return &osbResponse
 	}
-	var proxyRequest *http.Request
-	proxyRequest, osbResponse.err = o.client.config.HTTPRequestFactory(o.method, o.url, o.client.request.Header, bytes.NewReader(o.request))
+	_, span := tracing.StartSpan(o.client.request.Context(), "upstream "+o.method)
+	span.SetAttribute("http.url", o.url)
+	defer func() {
+		span.SetAttribute("http.status_code", strconv.Itoa(osbResponse.statusCode))
+		span.Finish(osbResponse.err)
+	}()
+	var header http.Header
+	header, osbResponse.err = upstreamHeader(o.client.config.UpstreamCredentials, o.client.request.Header, span)
 	if osbResponse.err != nil {
-		log.Errorf("error during create request: %s\n", osbResponse.err.Error())
+		osbResponse.logger.Errorf("error during load of upstream credentials: %s\n", osbResponse.err.Error())
 		return &osbResponse
 	}
-
 	var response *http.Response
-	response, osbResponse.err = o.client.Do(proxyRequest)
+	response, osbResponse.err = doUpstream(o.client.request.Context(), o.client.Client, o.client.config.Upstream, func() (*http.Request, error) {
+		proxyRequest, err := o.client.config.HTTPRequestFactory(o.method, o.url, header, bytes.NewReader(o.request))
+		if err != nil {
+			osbResponse.logger.Errorf("error during create request: %s\n", err.Error())
+		}
+		return proxyRequest, err
+	})
 	if osbResponse.err != nil {
-		log.Errorf("error during execute request: %s\n", osbResponse.err.Error())
+		osbResponse.logger.Errorf("error during execute request: %s\n", osbResponse.err.Error())
 		return &osbResponse
 	}
-	log.Infof("response status from %s: %s. %s=\"%s\"\n", o.url, response.Status, IstioBrokerVersion, response.Header.Get(IstioBrokerVersion))
+	osbResponse.logger.Infof("response status from %s: %s. %s=\"%s\"\n", o.url, response.Status, IstioBrokerVersion, response.Header.Get(IstioBrokerVersion))
+	osbResponse.statusCode = response.StatusCode
+	osbResponse.header = response.Header
 
 	defer response.Body.Close()
 
 	osbResponse.response, osbResponse.err = ioutil.ReadAll(response.Body)
 	if nil != osbResponse.err {
-		log.Errorf("error during read response: %s\n", osbResponse.err.Error())
+		osbResponse.logger.Errorf("error during read response: %s\n", osbResponse.err.Error())
 		return &osbResponse
 	}
 
@@ -108,7 +137,7 @@ func (o *restResponse) Into(result interface{}) error {
 
 	if nil != o.err {
 		o.err = fmt.Errorf("Can't unmarshal response from %s: %s", o.url, o.err.Error())
-		log.Errorf("ERROR: %s\n", o.err.Error())
+		o.logger.Errorf("ERROR: %s\n", o.err.Error())
 		return o.err
 	}
 	return nil
@@ -117,3 +146,11 @@ func (o *restResponse) Into(result interface{}) error {
 func (o *restResponse) Error() error {
 	return o.err
 }
+
+func (o *restResponse) StatusCode() int {
+	return o.statusCode
+}
+
+func (o *restResponse) Header() http.Header {
+	return o.header
+}
@@ -82,6 +82,19 @@ func TestRouterRestClientDelete(t *testing.T) {
 
 }
 
+func TestRouterRestClientSetQuery(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	handlerStub := newHandlerStub(http.StatusOK, []byte(`{}`))
+	server, routerConfig := injectClientStub(handlerStub)
+	defer server.Close()
+	client := &restClient{routerConfig.HTTPClientFactory(&http.Transport{}), &http.Request{URL: &url.URL{Path: "/v2/catalog", RawQuery: "a=b"}}, *routerConfig}
+	err := client.Delete().SetQuery(url.Values{"c": []string{"d"}}).Do().Error()
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(handlerStub.spy.url).To(Equal("http://xxxxx.xx/v2/catalog?c=d"))
+}
+
 func TestRouterRestClientWithMultilineErrorResponse(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+//ServerTLSConfig contains the settings for serving the proxy via HTTPS
+type ServerTLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables the verification of client certificates
+	ClientCAFile string
+	// AllowedClientIDs restricts the accepted client certificates to these subject alternative names, empty allows all
+	AllowedClientIDs []string
+}
+
+//Enabled returns true if the proxy should serve HTTPS
+func (c ServerTLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+//NewTLSConfig creates the tls.Config for the listener of the proxy
+func (c ServerTLSConfig) NewTLSConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("certificate and key file are required for TLS")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile == "" {
+		if len(c.AllowedClientIDs) != 0 {
+			return nil, errors.New("allowed client ids require a client CA file")
+		}
+		return tlsConfig, nil
+	}
+	caCerts, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caCerts) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", c.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	// the health check of kubernetes has no client certificate, so other requests without one are rejected by the router
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if len(c.AllowedClientIDs) != 0 {
+		tlsConfig.VerifyPeerCertificate = verifyClientID(c.AllowedClientIDs)
+	}
+	return tlsConfig, nil
+}
+
+// verifyClientID runs after the chain has been verified against the client CAs and checks the SANs of the leaf
+func verifyClientID(allowedClientIDs []string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	allowed := make(map[string]bool)
+	for _, clientID := range allowedClientIDs {
+		allowed[clientID] = true
+	}
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return nil
+		}
+		for _, chain := range verifiedChains {
+			if len(chain) == 0 {
+				continue
+			}
+			for _, name := range subjectAlternativeNames(chain[0]) {
+				if allowed[name] {
+					return nil
+				}
+			}
+		}
+		return errors.New("client certificate does not contain an allowed subject alternative name")
+	}
+}
+
+func subjectAlternativeNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
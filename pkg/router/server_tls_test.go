@@ -0,0 +1,61 @@
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestServerTLSConfigWithoutClientCA(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tlsConfig, err := ServerTLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}.NewTLSConfig()
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tlsConfig.ClientAuth).To(Equal(tls.NoClientCert))
+}
+
+func TestServerTLSConfigWithoutKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := ServerTLSConfig{CertFile: "cert.pem"}.NewTLSConfig()
+
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestServerTLSConfigAllowedClientIDsRequireClientCA(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := ServerTLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", AllowedClientIDs: []string{"client.istio.test"}}.NewTLSConfig()
+
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestServerTLSConfigInvalidClientCA(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := path.Join(os.TempDir(), "invalid-client-ca.pem")
+	defer os.Remove(fileName)
+	ioutil.WriteFile(fileName, []byte("no certificate"), 0644)
+
+	_, err := ServerTLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: fileName}.NewTLSConfig()
+
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("no certificates found"))
+}
+
+func TestVerifyClientID(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spiffeID, _ := url.Parse("spiffe://cluster.local/ns/default/sa/consumer")
+	verify := verifyClientID([]string{"client.istio.test", "spiffe://cluster.local/ns/default/sa/consumer"})
+
+	g.Expect(verify([][]byte{{}}, [][]*x509.Certificate{{{DNSNames: []string{"other.istio.test", "client.istio.test"}}}})).To(Succeed())
+	g.Expect(verify([][]byte{{}}, [][]*x509.Certificate{{{URIs: []*url.URL{spiffeID}}}})).To(Succeed())
+	g.Expect(verify([][]byte{{}}, [][]*x509.Certificate{{{DNSNames: []string{"other.istio.test"}}}})).NotTo(Succeed())
+	g.Expect(verify([][]byte{{}}, [][]*x509.Certificate{})).NotTo(Succeed())
+	g.Expect(verify(nil, nil)).To(Succeed())
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"github.com/Peripli/istio-broker-proxy/pkg/model"
 )
 
@@ -8,14 +9,34 @@ import (
 type ServiceBrokerInterceptor interface {
 	PreProvision(request model.ProvisionRequest) (*model.ProvisionRequest, error)
 	PostProvision(request model.ProvisionRequest, response model.ProvisionResponse) (*model.ProvisionResponse, error)
+	PreUpdate(request model.UpdateRequest) (*model.UpdateRequest, error)
+	PostUpdate(request model.UpdateRequest, response model.UpdateResponse) (*model.UpdateResponse, error)
+	PostLastOperation(response model.LastOperationResponse) (*model.LastOperationResponse, error)
 	PreBind(request model.BindRequest) (*model.BindRequest, error)
-	PostBind(request model.BindRequest, response model.BindResponse, bindID string,
+	PostBind(request model.BindRequest, response model.BindResponse, instanceID string, bindID string,
+		adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error)
+	PostAsyncBind(request model.BindRequest, instanceID string, bindID string) error
+	PostFetchBinding(response model.BindResponse, instanceID string, bindID string,
 		adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error)
 	PostUnbind(bindID string)
+	PostDeprovision(instanceID string)
+	// PostAsyncDeprovision records an accepted deprovisioning, PostLastOperationSucceeded completes it and returns true if there was one
+	PostAsyncDeprovision(instanceID string) error
+	PostLastOperationSucceeded(instanceID string) bool
 	PostCatalog(catalog *model.Catalog) error
 	HasAdaptCredentials() bool
 }
 
+//BrokerAPIVersionAware is implemented by interceptors which depend on the negotiated X-Broker-API-Version
+type BrokerAPIVersionAware interface {
+	WithBrokerAPIVersion(version model.BrokerAPIVersion) ServiceBrokerInterceptor
+}
+
+//ContextAware is implemented by interceptors which pass the context of the current request step to their ConfigStore
+type ContextAware interface {
+	WithContext(ctx context.Context) ServiceBrokerInterceptor
+}
+
 type noOpInterceptor struct {
 }
 
@@ -27,6 +48,18 @@ func (c noOpInterceptor) PostProvision(request model.ProvisionRequest, response
 	return &response, nil
 }
 
+func (c noOpInterceptor) PreUpdate(request model.UpdateRequest) (*model.UpdateRequest, error) {
+	return &request, nil
+}
+
+func (c noOpInterceptor) PostUpdate(request model.UpdateRequest, response model.UpdateResponse) (*model.UpdateResponse, error) {
+	return &response, nil
+}
+
+func (c noOpInterceptor) PostLastOperation(response model.LastOperationResponse) (*model.LastOperationResponse, error) {
+	return &response, nil
+}
+
 var _ ServiceBrokerInterceptor = &noOpInterceptor{}
 
 
@@ -34,7 +67,16 @@ func (c noOpInterceptor) PreBind(request model.BindRequest) (*model.BindRequest,
 	return &request, nil
 }
 
-func (c noOpInterceptor) PostBind(request model.BindRequest, response model.BindResponse, bindingID string,
+func (c noOpInterceptor) PostBind(request model.BindRequest, response model.BindResponse, instanceID string, bindingID string,
+	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error) {
+	return &response, nil
+}
+
+func (c noOpInterceptor) PostAsyncBind(request model.BindRequest, instanceID string, bindID string) error {
+	return nil
+}
+
+func (c noOpInterceptor) PostFetchBinding(response model.BindResponse, instanceID string, bindID string,
 	adapt func(model.Credentials, []model.EndpointMapping) (*model.BindResponse, error)) (*model.BindResponse, error) {
 	return &response, nil
 }
@@ -46,6 +88,17 @@ func (c noOpInterceptor) HasAdaptCredentials() bool {
 func (c noOpInterceptor) PostUnbind(bindID string) {
 }
 
+func (c noOpInterceptor) PostDeprovision(instanceID string) {
+}
+
+func (c noOpInterceptor) PostAsyncDeprovision(instanceID string) error {
+	return nil
+}
+
+func (c noOpInterceptor) PostLastOperationSucceeded(instanceID string) bool {
+	return false
+}
+
 func (c noOpInterceptor) PostCatalog(catalog *model.Catalog) error {
 	return nil
 }
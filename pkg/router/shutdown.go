@@ -0,0 +1,186 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gin-gonic/gin"
+	"istio.io/istio/pkg/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	shutdownError   = "ShuttingDown"
+	rollbackTimeout = 10 * time.Second
+)
+
+//Lifecycle tracks the binds in flight, so that a shutdown can wait for them and roll back those exceeding its deadline
+type Lifecycle struct {
+	mutex    sync.Mutex
+	stopping bool
+	draining bool
+	// inFlight counts the binds by the id of their request
+	inFlight  map[string]int
+	abort     chan struct{}
+	abortOnce sync.Once
+}
+
+//NewLifecycle creates the Lifecycle of a running proxy
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{inFlight: make(map[string]int), abort: make(chan struct{})}
+}
+
+//Name of the readiness check
+func (l *Lifecycle) Name() string {
+	return "shutdown"
+}
+
+//Check fails once the proxy is shutting down, so that no new requests are routed to it
+func (l *Lifecycle) Check(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.stopping {
+		return errors.New("proxy is shutting down")
+	}
+	return nil
+}
+
+//Draining returns true once the grace period is over and new binds are rejected
+func (l *Lifecycle) Draining() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.draining
+}
+
+//Shutdown stops the server gracefully. The readiness check fails at once, but requests are served for the grace
+//period until the endpoints are updated, which needs at least the period of the readiness probe. Then new binds are
+//rejected and binds in flight get until the deadline to finish. Those which don't are aborted and rolled back before
+//the server is closed.
+func (l *Lifecycle) Shutdown(server *http.Server, gracePeriod time.Duration, deadline time.Duration) error {
+	l.mutex.Lock()
+	l.stopping = true
+	l.mutex.Unlock()
+	log.Infof("Failing readiness for %s before shutting down\n", gracePeriod)
+	time.Sleep(gracePeriod)
+	l.mutex.Lock()
+	l.draining = true
+	l.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		return err
+	}
+	l.mutex.Lock()
+	for requestID := range l.inFlight {
+		newRequestLogger(requestID).Warnf("Shutdown deadline of %s exceeded, rolling back the bind in flight\n", deadline)
+	}
+	l.mutex.Unlock()
+	l.abortOnce.Do(func() { close(l.abort) })
+	rollbackContext, cancelRollback := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancelRollback()
+	if server.Shutdown(rollbackContext) != nil {
+		return server.Close()
+	}
+	return nil
+}
+
+func (l *Lifecycle) aborted() bool {
+	select {
+	case <-l.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// begin registers a bind unless the proxy is shutting down
+func (l *Lifecycle) begin(requestID string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.draining {
+		return false
+	}
+	l.inFlight[requestID]++
+	return true
+}
+
+func (l *Lifecycle) end(requestID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight[requestID]--
+	if l.inFlight[requestID] == 0 {
+		delete(l.inFlight, requestID)
+	}
+}
+
+type lifecycleKey struct{}
+
+// trackBind lets the shutdown wait for the bind and cancels its context once the bind is aborted
+func (client osbProxy) trackBind(ctx *gin.Context) {
+	lifecycle := client.config.Lifecycle
+	if lifecycle == nil {
+		return
+	}
+	requestID := requestIDFrom(ctx.Request.Context())
+	if !lifecycle.begin(requestID) {
+		httpError(ctx, model.HTTPError{ErrorMsg: shutdownError, Description: "The proxy is shutting down", StatusCode: http.StatusServiceUnavailable}, http.StatusServiceUnavailable)
+		return
+	}
+	defer lifecycle.end(requestID)
+	requestContext, cancel := context.WithCancel(context.WithValue(ctx.Request.Context(), lifecycleKey{}, lifecycle))
+	defer cancel()
+	go func() {
+		select {
+		case <-lifecycle.abort:
+			cancel()
+		case <-requestContext.Done():
+		}
+	}()
+	ctx.Request = ctx.Request.WithContext(requestContext)
+	ctx.Next()
+}
+
+func bindAborted(ctx context.Context) bool {
+	lifecycle, ok := ctx.Value(lifecycleKey{}).(*Lifecycle)
+	return ok && lifecycle.aborted()
+}
+
+// rollbackBind removes the istio config and the binding at the broker of a bind which was aborted by the shutdown,
+// unless the failed bind has already removed the binding itself
+func (client osbProxy) rollbackBind(request *http.Request, bindRequest model.BindRequest, bindingID string, bindErr error, orphanMitigated bool) error {
+	ctx, cancel := context.WithTimeout(detachedContext{request.Context()}, rollbackTimeout)
+	defer cancel()
+	requestLog(ctx).Warnf("Rolling back bind %s aborted by the shutdown\n", bindingID)
+	osbClient := client.newInterceptedOsbClient(request.WithContext(ctx))
+	osbClient.intercept("PostUnbind", func(interceptor ServiceBrokerInterceptor) error {
+		interceptor.PostUnbind(bindingID)
+		return nil
+	})
+	abortError := model.HTTPError{ErrorMsg: shutdownError, Description: "The proxy is shutting down, the bind was aborted", StatusCode: http.StatusServiceUnavailable}
+	if orphanMitigated {
+		abortError.Description = fmt.Sprintf("%s: %s", abortError.Description, model.HTTPErrorFromError(bindErr, http.StatusInternalServerError).Description)
+		return abortError
+	}
+	return osbClient.mitigateOrphan(bindRequest, abortError)
+}
+
+// detachedContext keeps the values of the request, e.g. its id, but not its cancellation, so that a rollback completes
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdownFailsReadinessAndRejectsBinds(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lifecycle := NewLifecycle()
+	router := SetupRouter(noOpInterceptor{}, Config{Lifecycle: lifecycle})
+	server := httptest.NewServer(router)
+
+	g.Expect(lifecycle.Shutdown(server.Config, 0, time.Second)).To(Succeed())
+	server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, "https://blablub.org/ready", bytes.NewReader([]byte("")))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	g.Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+	g.Expect(response.Body.String()).To(MatchJSON(`{"status": "DOWN", "checks": {"shutdown": {"status": "DOWN", "error": "proxy is shutting down"}}}`))
+
+	request, _ = http.NewRequest(http.MethodPut, "https://blablub.org/v2/service_instances/1/service_bindings/2", bytes.NewReader([]byte(`{}`)))
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	g.Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+	var err model.HTTPError
+	json.Unmarshal(response.Body.Bytes(), &err)
+	g.Expect(err.ErrorMsg).To(Equal(shutdownError))
+}
+
+func TestShutdownRollsBackBindsExceedingTheDeadline(t *testing.T) {
+	g := NewGomegaWithT(t)
+	bindReceived := make(chan struct{})
+	release := make(chan struct{})
+	var unbindQuery string
+	broker := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodPut {
+			close(bindReceived)
+			<-release
+			return
+		}
+		unbindQuery = request.URL.RawQuery
+		writer.Write([]byte(`{}`))
+	}))
+	defer broker.Close()
+	lifecycle := NewLifecycle()
+	interceptor := ConsumerInterceptor{ConsumerID: "consumer-id", NetworkProfile: "urn:local.test:public", ConfigStore: NewMockConfigStore()}
+	server := httptest.NewServer(SetupRouter(interceptor, Config{ForwardURL: broker.URL, Lifecycle: lifecycle}))
+	defer server.Close()
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		request, _ := http.NewRequest(http.MethodPut, server.URL+"/v2/service_instances/1/service_bindings/2",
+			bytes.NewReader([]byte(`{"service_id": "service-id", "plan_id": "plan-id"}`)))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			panic(err)
+		}
+		responses <- response
+	}()
+	<-bindReceived
+
+	g.Expect(lifecycle.Shutdown(server.Config, 0, 50*time.Millisecond)).To(Succeed())
+	close(release)
+
+	response := <-responses
+	defer response.Body.Close()
+	g.Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	var err model.HTTPError
+	json.NewDecoder(response.Body).Decode(&err)
+	g.Expect(err.ErrorMsg).To(Equal(shutdownError))
+	g.Expect(err.Description).To(ContainSubstring("orphan mitigation succeeded"))
+	g.Expect(unbindQuery).To(Equal("plan_id=plan-id&service_id=service-id"))
+}
+
+func TestShutdownServesDuringGracePeriod(t *testing.T) {
+	g := NewGomegaWithT(t)
+	lifecycle := NewLifecycle()
+	server := httptest.NewServer(SetupRouter(noOpInterceptor{}, Config{Lifecycle: lifecycle}))
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- lifecycle.Shutdown(server.Config, 200*time.Millisecond, time.Second)
+	}()
+
+	g.Eventually(func() error { return lifecycle.Check(context.Background()) }).Should(HaveOccurred())
+	g.Expect(lifecycle.Draining()).To(BeFalse())
+	response, err := http.Get(server.URL + "/ready")
+	g.Expect(err).NotTo(HaveOccurred())
+	response.Body.Close()
+	g.Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+	g.Expect(<-done).To(Succeed())
+	g.Expect(lifecycle.Draining()).To(BeTrue())
+}
+
+func TestRollbackSkipsOrphanMitigationDoneByBind(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub := newHandlerStub(http.StatusOK, []byte(`{}`))
+	server, routerConfig := injectClientStub(handlerStub)
+	defer server.Close()
+	proxy := newOsbProxy(noOpInterceptor{}, *routerConfig)
+	request, _ := http.NewRequest(http.MethodPut, "https://blablub.org/v2/service_instances/1/service_bindings/2", bytes.NewReader([]byte(`{}`)))
+	bindErr := model.HTTPError{ErrorMsg: "PostBindFailed", Description: "unable to create service; orphan mitigation succeeded: binding removed from broker", StatusCode: http.StatusInternalServerError}
+
+	err := proxy.rollbackBind(request, model.BindRequest{}, "2", bindErr, true)
+
+	g.Expect(handlerStub.spy.method).To(BeEmpty())
+	g.Expect(err.(model.HTTPError).ErrorMsg).To(Equal(shutdownError))
+	g.Expect(err.(model.HTTPError).Description).To(HaveSuffix("orphan mitigation succeeded: binding removed from broker"))
+
+	err = proxy.rollbackBind(request, model.BindRequest{}, "2", bindErr, false)
+
+	g.Expect(handlerStub.spy.method).To(Equal(http.MethodDelete))
+	g.Expect(err.(model.HTTPError).Description).To(Equal("The proxy is shutting down, the bind was aborted; orphan mitigation succeeded: binding removed from broker"))
+}
@@ -13,12 +13,14 @@ type requestSpy struct {
 	url    string
 	body   []string
 	tr     *http.Transport
+	header http.Header
 }
 
 type handlerStub struct {
 	code    int
 	handler func([]byte) []byte
 	spy     requestSpy
+	header  http.Header
 }
 
 func (stub handlerStub) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
@@ -26,18 +28,21 @@ func (stub handlerStub) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 	if nil != err {
 		panic(err)
 	}
+	for name, values := range stub.header {
+		writer.Header()[name] = values
+	}
 	writer.Header().Add("Content-Type","application/json")
 	writer.WriteHeader(stub.code)
 	writer.Write(stub.handler(bodyAsBytes))
 }
 
 func newHandlerStub(code int, responseBody []byte) *handlerStub {
-	stub := handlerStub{code, func([]byte) []byte { return responseBody }, requestSpy{}}
+	stub := handlerStub{code, func([]byte) []byte { return responseBody }, requestSpy{}, nil}
 	return &stub
 }
 
 func newHandlerStubWithFunc(code int, handler func([]byte) []byte) *handlerStub {
-	stub := handlerStub{code, handler,requestSpy{}}
+	stub := handlerStub{code, handler,requestSpy{}, nil}
 	return &stub
 }
 
@@ -54,6 +59,7 @@ func injectClientStub(handler *handlerStub) (*httptest.Server, *Config) {
 	routerConfig.HTTPRequestFactory = func(method string, url string, header http.Header, body io.Reader) (*http.Request, error) {
 		handler.spy.method = method
 		handler.spy.url = url
+		handler.spy.header = header
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(body)
 
@@ -0,0 +1,127 @@
+package router
+
+import (
+	"context"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/Peripli/istio-broker-proxy/pkg/tracing"
+	istioModel "istio.io/istio/pilot/pkg/model"
+	"k8s.io/api/core/v1"
+)
+
+type tracedConfigStore struct {
+	ctx      context.Context
+	delegate ConfigStore
+}
+
+// newTracedConfigStore records a span for each call as child of the span in the given context
+func newTracedConfigStore(ctx context.Context, configStore ConfigStore) ConfigStore {
+	if configStore == nil {
+		return nil
+	}
+	if traced, ok := configStore.(*tracedConfigStore); ok {
+		configStore = traced.delegate
+	}
+	return &tracedConfigStore{ctx, configStoreWithContext(ctx, configStore)}
+}
+
+func (s *tracedConfigStore) startSpan(method string, bindingID string) *tracing.Span {
+	_, span := tracing.StartSpan(s.ctx, "ConfigStore."+method)
+	if bindingID != "" {
+		span.SetAttribute("binding_id", bindingID)
+	}
+	return span
+}
+
+func (s *tracedConfigStore) CreateService(instanceID string, bindingID string, service *v1.Service) (*v1.Service, error) {
+	span := s.startSpan("CreateService", bindingID)
+	result, err := s.delegate.CreateService(instanceID, bindingID, service)
+	span.Finish(err)
+	return result, err
+}
+
+func (s *tracedConfigStore) GetService(bindingID string, name string) (*v1.Service, error) {
+	span := s.startSpan("GetService", bindingID)
+	result, err := s.delegate.GetService(bindingID, name)
+	span.Finish(err)
+	return result, err
+}
+
+func (s *tracedConfigStore) DeleteService(bindingID string, name string) error {
+	span := s.startSpan("DeleteService", bindingID)
+	err := s.delegate.DeleteService(bindingID, name)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) ApplyIstioConfig(instanceID string, bindingID string, config []istioModel.Config) error {
+	span := s.startSpan("ApplyIstioConfig", bindingID)
+	err := s.delegate.ApplyIstioConfig(instanceID, bindingID, config)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) DeleteBinding(bindingID string) error {
+	span := s.startSpan("DeleteBinding", bindingID)
+	err := s.delegate.DeleteBinding(bindingID)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) DeleteInstance(instanceID string) error {
+	span := s.startSpan("DeleteInstance", "")
+	span.SetAttribute("instance_id", instanceID)
+	err := s.delegate.DeleteInstance(instanceID)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) StoreBindRequest(instanceID string, bindingID string, request model.BindRequest) error {
+	span := s.startSpan("StoreBindRequest", bindingID)
+	err := s.delegate.StoreBindRequest(instanceID, bindingID, request)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) LoadBindRequest(bindingID string) (*model.BindRequest, error) {
+	span := s.startSpan("LoadBindRequest", bindingID)
+	result, err := s.delegate.LoadBindRequest(bindingID)
+	span.Finish(err)
+	return result, err
+}
+
+func (s *tracedConfigStore) DeleteBindRequest(bindingID string) error {
+	span := s.startSpan("DeleteBindRequest", bindingID)
+	err := s.delegate.DeleteBindRequest(bindingID)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) StoreDeprovision(instanceID string) error {
+	span := s.startSpan("StoreDeprovision", "")
+	span.SetAttribute("instance_id", instanceID)
+	err := s.delegate.StoreDeprovision(instanceID)
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) IsDeprovisioning(instanceID string) (bool, error) {
+	span := s.startSpan("IsDeprovisioning", "")
+	span.SetAttribute("instance_id", instanceID)
+	result, err := s.delegate.IsDeprovisioning(instanceID)
+	span.Finish(err)
+	return result, err
+}
+
+func (s *tracedConfigStore) Health() error {
+	span := s.startSpan("Health", "")
+	err := s.delegate.Health()
+	span.Finish(err)
+	return err
+}
+
+func (s *tracedConfigStore) CountBindings() (int, error) {
+	span := s.startSpan("CountBindings", "")
+	count, err := s.delegate.CountBindings()
+	span.Finish(err)
+	return count, err
+}
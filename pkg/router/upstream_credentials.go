@@ -0,0 +1,82 @@
+package router
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/tracing"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//UpstreamCredentials provide the authorization of the proxy at the ForwardURL
+type UpstreamCredentials interface {
+	Authorization() (string, error)
+}
+
+type fileUpstreamCredentials struct {
+	file *reloadingFile
+}
+
+//NewFileUpstreamCredentials creates UpstreamCredentials from a file containing <user>:<password>, which is
+//reloaded whenever it changes
+func NewFileUpstreamCredentials(fileName string) UpstreamCredentials {
+	return &fileUpstreamCredentials{&reloadingFile{path: fileName}}
+}
+
+func (c *fileUpstreamCredentials) Authorization() (string, error) {
+	lines, err := c.file.lines()
+	if err != nil {
+		return "", err
+	}
+	if len(lines) != 1 {
+		return "", fmt.Errorf("expected exactly one <user>:<password> line in %s", c.file.path)
+	}
+	return basicAuthorization(lines[0])
+}
+
+type envUpstreamCredentials struct {
+	variable string
+}
+
+//NewEnvUpstreamCredentials creates UpstreamCredentials from an environment variable containing <user>:<password>
+func NewEnvUpstreamCredentials(variable string) UpstreamCredentials {
+	return &envUpstreamCredentials{variable}
+}
+
+func (c *envUpstreamCredentials) Authorization() (string, error) {
+	value, ok := os.LookupEnv(c.variable)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", c.variable)
+	}
+	return basicAuthorization(value)
+}
+
+func basicAuthorization(userAndPassword string) (string, error) {
+	if strings.Index(userAndPassword, ":") <= 0 {
+		return "", errors.New("upstream credentials need to be of the form <user>:<password>")
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(userAndPassword)), nil
+}
+
+// upstreamHeader replaces the authorization of the platform with the configured upstream credentials, so that the
+// platform and the broker don't need to share a secret, and propagates the trace context of the given span.
+// The incoming header is left untouched.
+func upstreamHeader(credentials UpstreamCredentials, header http.Header, span *tracing.Span) (http.Header, error) {
+	upstream := http.Header{}
+	for name, values := range header {
+		upstream[name] = append([]string{}, values...)
+	}
+	if credentials != nil {
+		authorization, err := credentials.Authorization()
+		if err != nil {
+			return nil, err
+		}
+		upstream.Set("Authorization", authorization)
+	}
+	if span != nil {
+		span.Inject(upstream)
+	}
+	return upstream, nil
+}
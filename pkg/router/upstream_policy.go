@@ -0,0 +1,179 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//UpstreamPolicy contains the timeouts and retries of the calls to the broker at the ForwardURL
+type UpstreamPolicy struct {
+	// Timeout of each attempt, zero waits until the incoming request is cancelled
+	Timeout time.Duration
+	// OperationTimeouts overrides the Timeout per operation, e.g. "bind" or "catalog"
+	OperationTimeouts map[string]time.Duration
+	// Retries of the idempotent operations after connection errors, timeouts and 502, 503 or 504 responses
+	Retries int
+	// InitialBackoff is the delay before the first retry, it doubles with each further retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// the idempotent operations, retrying the others could e.g. create a second binding
+var retryableOperations = map[string]bool{
+	"catalog":       true,
+	"unbind":        true,
+	"fetch_binding": true,
+}
+
+type operationKey struct{}
+
+// setOperation makes the OSB operation of the route available to the upstream calls
+func setOperation(operation string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), operationKey{}, operation))
+	}
+}
+
+func operationFrom(ctx context.Context) string {
+	operation, _ := ctx.Value(operationKey{}).(string)
+	return operation
+}
+
+func (p UpstreamPolicy) timeout(operation string) time.Duration {
+	if timeout, ok := p.OperationTimeouts[operation]; ok {
+		return timeout
+	}
+	return p.Timeout
+}
+
+func (p UpstreamPolicy) retries(operation string) int {
+	if !retryableOperations[operation] {
+		return 0
+	}
+	return p.Retries
+}
+
+func (p UpstreamPolicy) backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 0; i < retry; i++ {
+		backoff *= 2
+		if p.MaxBackoff != 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff != 0 && backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// doUpstream sends the requests created by newRequest until one succeeds or the retries of the operation in the
+// context are exhausted. Each attempt is cancelled with the incoming request or after the timeout of the operation.
+func doUpstream(ctx context.Context, client *http.Client, policy UpstreamPolicy, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	operation := operationFrom(ctx)
+	timeout := policy.timeout(operation)
+	retries := policy.retries(operation)
+	for attempt := 0; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		response, err := doAttempt(ctx, client, request, timeout)
+		if attempt == retries || ctx.Err() != nil || !isRetryable(response, err) {
+			return response, err
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+		backoff := policy.backoff(attempt)
+		requestLog(ctx).Warnf("Retrying %s %s in %s after attempt %d failed: %s\n", request.Method, request.URL, backoff, attempt+1, describeFailure(response, err))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func doAttempt(ctx context.Context, client *http.Client, request *http.Request, timeout time.Duration) (*http.Response, error) {
+	var attemptContext context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		attemptContext, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		attemptContext, cancel = context.WithCancel(ctx)
+	}
+	response, err := client.Do(request.WithContext(attemptContext))
+	if err != nil {
+		cancel()
+		if httpError, ok := isCircuitOpen(err); ok {
+			return nil, httpError
+		}
+		if attemptContext.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return nil, model.HTTPError{ErrorMsg: "GatewayTimeout", Description: fmt.Sprintf("no response from %s %s within %s", request.Method, request.URL, timeout), StatusCode: http.StatusGatewayTimeout}
+		}
+		return nil, err
+	}
+	// the timeout covers reading the body, so the attempt is only cancelled when the body is closed
+	response.Body = &cancelOnClose{response.Body, cancel}
+	return response, nil
+}
+
+func isRetryable(response *http.Response, err error) bool {
+	if _, ok := isCircuitOpen(err); ok {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func describeFailure(response *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return response.Status
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+//ParseOperationTimeouts parses timeouts per operation given as comma separated <operation>=<duration> pairs,
+//e.g. bind=2m,catalog=10s
+func ParseOperationTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid operation timeout %q, expected <operation>=<duration>", entry)
+		}
+		timeout, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout of operation %s: %v", parts[0], err)
+		}
+		timeouts[parts[0]] = timeout
+	}
+	return timeouts, nil
+}
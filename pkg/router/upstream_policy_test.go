@@ -0,0 +1,147 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Peripli/istio-broker-proxy/pkg/model"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newFlakyHandlerStub responds with the given code to all calls before the succeedingCall
+func newFlakyHandlerStub(code int, succeedingCall int, responseBody []byte) (*handlerStub, *int) {
+	calls := 0
+	var stub *handlerStub
+	stub = newHandlerStubWithFunc(code, func([]byte) []byte {
+		calls++
+		if calls+1 >= succeedingCall {
+			stub.code = http.StatusOK
+		}
+		return responseBody
+	})
+	return stub, &calls
+}
+
+func TestIdempotentOperationsAreRetried(t *testing.T) {
+	g := NewGomegaWithT(t)
+	for _, testCase := range []struct{ method, path string }{
+		{http.MethodGet, "/v2/catalog"},
+		{http.MethodGet, "/v2/service_instances/123/service_bindings/456"},
+		{http.MethodDelete, "/v2/service_instances/123/service_bindings/456"},
+	} {
+		handlerStub, calls := newFlakyHandlerStub(http.StatusServiceUnavailable, 3, []byte(`{}`))
+		server, routerConfig := injectClientStub(handlerStub)
+		routerConfig.Upstream = UpstreamPolicy{Retries: 2, InitialBackoff: time.Millisecond}
+		router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+
+		request, _ := http.NewRequest(testCase.method, "https://blahblubs.org"+testCase.path, bytes.NewReader(make([]byte, 0)))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		server.Close()
+
+		g.Expect(response.Code).To(Equal(http.StatusOK), testCase.path)
+		g.Expect(*calls).To(Equal(3), testCase.path)
+	}
+}
+
+func TestRetriesAreLimited(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub, calls := newFlakyHandlerStub(http.StatusBadGateway, 10, []byte(`{}`))
+	server, routerConfig := injectClientStub(handlerStub)
+	defer server.Close()
+	routerConfig.Upstream = UpstreamPolicy{Retries: 2, InitialBackoff: time.Millisecond}
+	router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", bytes.NewReader(make([]byte, 0)))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	g.Expect(response.Code).To(Equal(http.StatusBadGateway))
+	g.Expect(*calls).To(Equal(3))
+}
+
+func TestBindIsNotRetried(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub, calls := newFlakyHandlerStub(http.StatusServiceUnavailable, 2, []byte(`{}`))
+	server, routerConfig := injectClientStub(handlerStub)
+	defer server.Close()
+	routerConfig.Upstream = UpstreamPolicy{Retries: 2, InitialBackoff: time.Millisecond}
+	router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+
+	request, _ := http.NewRequest(http.MethodPut, "https://blahblubs.org/v2/service_instances/123/service_bindings/456", bytes.NewReader([]byte(`{}`)))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	g.Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+	g.Expect(*calls).To(Equal(1))
+}
+
+func TestUpstreamTimeoutPerOperation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	handlerStub := newHandlerStubWithFunc(http.StatusOK, func([]byte) []byte {
+		time.Sleep(200 * time.Millisecond)
+		return []byte(`{}`)
+	})
+	server, routerConfig := injectClientStub(handlerStub)
+	defer server.Close()
+	routerConfig.Upstream = UpstreamPolicy{Timeout: time.Minute, OperationTimeouts: map[string]time.Duration{"catalog": 20 * time.Millisecond}}
+	router := SetupRouter(&noOpInterceptor{}, *routerConfig)
+
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", bytes.NewReader(make([]byte, 0)))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	g.Expect(response.Code).To(Equal(http.StatusGatewayTimeout))
+	var err model.HTTPError
+	json.Unmarshal(response.Body.Bytes(), &err)
+	g.Expect(err.ErrorMsg).To(Equal("GatewayTimeout"))
+}
+
+func TestCancellationOfIncomingRequestIsPropagated(t *testing.T) {
+	g := NewGomegaWithT(t)
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+		close(cancelled)
+	}))
+	defer server.Close()
+	routerConfig := Config{ForwardURL: server.URL, Upstream: UpstreamPolicy{Retries: 2}}
+	router := SetupRouter(&noOpInterceptor{}, routerConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request, _ := http.NewRequest(http.MethodGet, "https://blahblubs.org/v2/catalog", bytes.NewReader(make([]byte, 0)))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), request.WithContext(ctx))
+
+	g.Eventually(cancelled).Should(BeClosed())
+}
+
+func TestBackoffIsDoubledUpToMaximum(t *testing.T) {
+	g := NewGomegaWithT(t)
+	policy := UpstreamPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	g.Expect(policy.backoff(0)).To(Equal(100 * time.Millisecond))
+	g.Expect(policy.backoff(1)).To(Equal(200 * time.Millisecond))
+	g.Expect(policy.backoff(3)).To(Equal(800 * time.Millisecond))
+	g.Expect(policy.backoff(4)).To(Equal(time.Second))
+	g.Expect(policy.backoff(100)).To(Equal(time.Second))
+}
+
+func TestParseOperationTimeouts(t *testing.T) {
+	g := NewGomegaWithT(t)
+	timeouts, err := ParseOperationTimeouts("bind=2m, catalog=10s")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(timeouts).To(Equal(map[string]time.Duration{"bind": 2 * time.Minute, "catalog": 10 * time.Second}))
+
+	_, err = ParseOperationTimeouts("bind")
+	g.Expect(err).To(HaveOccurred())
+	_, err = ParseOperationTimeouts("bind=soon")
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,38 @@
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+func newUpstreamTLSConfig(routerConfig Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: routerConfig.SkipVerifyTLS,
+		ServerName:         routerConfig.ServerName,
+	}
+	if routerConfig.CACertFile != "" {
+		caCerts, err := ioutil.ReadFile(routerConfig.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", routerConfig.CACertFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if routerConfig.ClientCertFile != "" || routerConfig.ClientKeyFile != "" {
+		if routerConfig.ClientCertFile == "" || routerConfig.ClientKeyFile == "" {
+			return nil, errors.New("client certificate and key file have to be configured together")
+		}
+		certificate, err := tls.LoadX509KeyPair(routerConfig.ClientCertFile, routerConfig.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func writeSelfSignedCertificate(g *GomegaWithT, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	g.Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	certFile := path.Join(os.TempDir(), name+".crt")
+	keyFile := path.Join(os.TempDir(), name+".key")
+	g.Expect(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)).To(Succeed())
+	g.Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).To(Succeed())
+	return certFile, keyFile
+}
+
+func TestUpstreamTLSConfig(t *testing.T) {
+	g := NewGomegaWithT(t)
+	caFile, caKeyFile := writeSelfSignedCertificate(g, "ca.istio.test")
+	defer os.Remove(caFile)
+	defer os.Remove(caKeyFile)
+	certFile, keyFile := writeSelfSignedCertificate(g, "client.istio.test")
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	tlsConfig, err := newUpstreamTLSConfig(Config{CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile, ServerName: "broker.istio.test"})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tlsConfig.InsecureSkipVerify).To(BeFalse())
+	g.Expect(tlsConfig.RootCAs).NotTo(BeNil())
+	g.Expect(tlsConfig.Certificates).To(HaveLen(1))
+	g.Expect(tlsConfig.ServerName).To(Equal("broker.istio.test"))
+}
+
+func TestUpstreamTLSConfigWithoutSettings(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tlsConfig, err := newUpstreamTLSConfig(Config{SkipVerifyTLS: true})
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tlsConfig.InsecureSkipVerify).To(BeTrue())
+	g.Expect(tlsConfig.RootCAs).To(BeNil())
+	g.Expect(tlsConfig.Certificates).To(BeEmpty())
+}
+
+func TestUpstreamTLSConfigInvalidCAFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fileName := path.Join(os.TempDir(), "invalid-ca.pem")
+	defer os.Remove(fileName)
+	ioutil.WriteFile(fileName, []byte("no certificate"), 0644)
+
+	_, err := newUpstreamTLSConfig(Config{CACertFile: fileName})
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = newUpstreamTLSConfig(Config{CACertFile: "/invalid-directory/ca.pem"})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestUpstreamTLSConfigClientCertificateWithoutKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+	certFile, keyFile := writeSelfSignedCertificate(g, "client.istio.test")
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	_, err := newUpstreamTLSConfig(Config{ClientCertFile: certFile})
+
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"encoding/json"
+	"istio.io/istio/pkg/log"
+	"sync"
+)
+
+//Exporter receives all finished and sampled spans
+type Exporter interface {
+	Export(span Span)
+}
+
+var (
+	exporterMutex sync.RWMutex
+	exporter      Exporter = noOpExporter{}
+)
+
+//SetExporter replaces the exporter of all spans, nil disables the export
+func SetExporter(e Exporter) {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	if e == nil {
+		e = noOpExporter{}
+	}
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	exporterMutex.RLock()
+	defer exporterMutex.RUnlock()
+	return exporter
+}
+
+type noOpExporter struct{}
+
+func (noOpExporter) Export(span Span) {}
+
+type logExporter struct{}
+
+//NewLogExporter creates an Exporter which logs each span as json
+func NewLogExporter() Exporter {
+	return logExporter{}
+}
+
+func (logExporter) Export(span Span) {
+	data, err := json.Marshal(struct {
+		TraceID    string            `json:"trace_id"`
+		SpanID     string            `json:"span_id"`
+		ParentID   string            `json:"parent_id,omitempty"`
+		Name       string            `json:"name"`
+		Start      string            `json:"start"`
+		DurationMs float64           `json:"duration_ms"`
+		Attributes map[string]string `json:"attributes,omitempty"`
+		Error      string            `json:"error,omitempty"`
+	}{span.Context.TraceID, span.Context.SpanID, span.ParentSpanID, span.Name, span.Start.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		float64(span.End.Sub(span.Start).Nanoseconds()) / 1e6, span.Attributes, span.Error})
+	if err != nil {
+		log.Warnf("Unable to export span %s: %v\n", span.Name, err)
+		return
+	}
+	log.Infof("span %s\n", data)
+}
+
+//RecordingExporter keeps all spans in memory, e.g. as collector in tests
+type RecordingExporter struct {
+	mutex sync.Mutex
+	spans []Span
+}
+
+//Export see interface definition
+func (e *RecordingExporter) Export(span Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+//Spans returns the recorded spans in the order they have been finished
+func (e *RecordingExporter) Spans() []Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]Span{}, e.spans...)
+}
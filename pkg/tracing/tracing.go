@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// TraceparentHeader propagates the trace context as defined by https://www.w3.org/TR/trace-context/
+	TraceparentHeader = "traceparent"
+
+	traceparentVersion = "00"
+	sampledFlag        = "01"
+	notSampledFlag     = "00"
+)
+
+type spanKey struct{}
+
+//SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+//ParseTraceparent reads the span context of a traceparent header value
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(flags, 2) ||
+		traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	flagBits, _ := hex.DecodeString(flags)
+	return SpanContext{TraceID: traceID, SpanID: spanID, Sampled: flagBits[0]&1 == 1}, nil
+}
+
+//Traceparent formats the span context as traceparent header value
+func (c SpanContext) Traceparent() string {
+	flags := notSampledFlag
+	if c.Sampled {
+		flags = sampledFlag
+	}
+	return strings.Join([]string{traceparentVersion, c.TraceID, c.SpanID, flags}, "-")
+}
+
+func isHex(value string, length int) bool {
+	if len(value) != length || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+//Span is a timed operation within a trace
+type Span struct {
+	Name         string
+	Context      SpanContext
+	ParentSpanID string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	Error        string
+}
+
+//SetAttribute annotates the span, a span must only be modified by the goroutine which started it
+func (s *Span) SetAttribute(key string, value string) {
+	s.Attributes[key] = value
+}
+
+//Finish ends the span and hands it to the exporter if it is sampled
+func (s *Span) Finish(err error) {
+	s.End = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	if s.Context.Sampled {
+		currentExporter().Export(*s)
+	}
+}
+
+//Inject adds the traceparent of the span to the header
+func (s *Span) Inject(header http.Header) {
+	header.Set(TraceparentHeader, s.Context.Traceparent())
+}
+
+//StartSpan starts a child of the span in the context or a new trace if there is none
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	if parent := FromContext(ctx); parent != nil {
+		return start(ctx, name, parent.Context)
+	}
+	return start(ctx, name, SpanContext{})
+}
+
+//StartSpanFromHeader starts a child of the span propagated via the traceparent header or a new trace if there is none
+func StartSpanFromHeader(ctx context.Context, name string, header http.Header) (context.Context, *Span) {
+	parent, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return start(ctx, name, SpanContext{})
+	}
+	return start(ctx, name, parent)
+}
+
+func start(ctx context.Context, name string, parent SpanContext) (context.Context, *Span) {
+	span := &Span{Name: name, Start: time.Now(), Attributes: make(map[string]string)}
+	if parent.TraceID == "" {
+		span.Context = SpanContext{TraceID: randomID(16), SpanID: randomID(8), Sampled: true}
+	} else {
+		span.Context = SpanContext{TraceID: parent.TraceID, SpanID: randomID(8), Sampled: parent.Sampled}
+		span.ParentSpanID = parent.SpanID
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+//FromContext returns the current span or nil
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func randomID(bytes int) string {
+	id := make([]byte, bytes)
+	_, err := rand.Read(id)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spanContext, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(spanContext).To(Equal(SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}))
+	g.Expect(spanContext.Traceparent()).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+}
+
+func TestParseInvalidTraceparent(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for _, traceparent := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, err := ParseTraceparent(traceparent)
+		g.Expect(err).To(HaveOccurred(), traceparent)
+	}
+}
+
+func TestSpansOfOneRequestFormOneTrace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	exporter := &RecordingExporter{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := StartSpanFromHeader(context.Background(), "server", header)
+	_, child := StartSpan(ctx, "child")
+	child.Finish(errors.New("failed"))
+	server.Finish(nil)
+
+	spans := exporter.Spans()
+	g.Expect(spans).To(HaveLen(2))
+	g.Expect(spans[0].Name).To(Equal("child"))
+	g.Expect(spans[0].Context.TraceID).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+	g.Expect(spans[0].ParentSpanID).To(Equal(server.Context.SpanID))
+	g.Expect(spans[0].Error).To(Equal("failed"))
+	g.Expect(spans[1].ParentSpanID).To(Equal("00f067aa0ba902b7"))
+	g.Expect(FromContext(ctx)).To(Equal(server))
+}
+
+func TestNewTraceWithoutParent(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, span := StartSpanFromHeader(context.Background(), "server", http.Header{})
+
+	g.Expect(span.Context.TraceID).To(HaveLen(32))
+	g.Expect(span.Context.SpanID).To(HaveLen(16))
+	g.Expect(span.Context.Sampled).To(BeTrue())
+	g.Expect(span.ParentSpanID).To(BeEmpty())
+}
+
+func TestUnsampledSpansAreNotExported(t *testing.T) {
+	g := NewGomegaWithT(t)
+	exporter := &RecordingExporter{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := StartSpanFromHeader(context.Background(), "server", header)
+	span.Finish(nil)
+
+	injected := http.Header{}
+	span.Inject(injected)
+	g.Expect(injected.Get(TraceparentHeader)).To(HaveSuffix("-00"))
+	g.Expect(exporter.Spans()).To(BeEmpty())
+}